@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"log"
+
+	"github.com/google/go-github/v28/github"
+)
+
+// CompletedChecksFilter decides whether the final checks of a pull request,
+// reported through a completion event, are notified.
+type CompletedChecksFilter func(pr *github.PullRequest, checks Checks) bool
+
+// NotifyWorkflowRunCompleted posts the final checks of the pull requests
+// associated with a completed workflow run. Unlike polling, this is done once
+// when GitHub reports the completion, so no runner has to wait for the checks.
+func (a *HangoutsAction) NotifyWorkflowRunCompleted(ctx context.Context, event *WorkflowRunEvent, filters ...CompletedChecksFilter) error {
+	if event.Action == nil || *event.Action != "completed" {
+		return nil
+	}
+	run := event.WorkflowRun
+	return a.notifyCompletedChecks(ctx, event.Repo, *run.HeadSHA, run.PullRequests, filters)
+}
+
+// NotifyCheckSuiteCompleted posts the final checks of the pull requests
+// associated with a completed check suite.
+func (a *HangoutsAction) NotifyCheckSuiteCompleted(ctx context.Context, event *github.CheckSuiteEvent, filters ...CompletedChecksFilter) error {
+	if *event.Action != "completed" {
+		return nil
+	}
+	suite := event.CheckSuite
+	return a.notifyCompletedChecks(ctx, event.Repo, *suite.HeadSHA, suite.PullRequests, filters)
+}
+
+func (a *HangoutsAction) notifyCompletedChecks(ctx context.Context, repository *github.Repository, headSha string, prs []*github.PullRequest, filters []CompletedChecksFilter) error {
+	repo := *repository.Name
+	owner := *repository.Owner.Login
+	if len(prs) == 0 {
+		// GitHub does not associate pull requests from forks with the run
+		log.Printf("no pull requests associated with %s, nothing to notify", headSha)
+		return nil
+	}
+	checks, err := a.GetChecks(ctx, owner, repo, headSha)
+	if err != nil {
+		return err
+	}
+	if checks.Empty() {
+		return nil
+	}
+	if checks.OverallStatus() == StatusInProgress {
+		// The completion of the remaining checks triggers another event
+		log.Printf("checks of %s are still running, nothing to notify", headSha)
+		return nil
+	}
+	for _, p := range prs {
+		// The payload only has the number and refs of the pull request
+		pr, _, err := a.githubClient.PullRequests.Get(ctx, owner, repo, *p.Number)
+		if err != nil {
+			return err
+		}
+		if pr.GetHead().GetSHA() != headSha {
+			log.Printf("pull request #%d has moved on from %s, skipping", *p.Number, headSha)
+			continue
+		}
+		notify := true
+		for _, filter := range filters {
+			if !filter(pr, checks) {
+				notify = false
+				break
+			}
+		}
+		if !notify {
+			continue
+		}
+		if err := a.sendChecks(ctx, owner, repo, pr, checks); err != nil {
+			return err
+		}
+	}
+	return nil
+}
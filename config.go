@@ -0,0 +1,343 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mirage20/hangouts-action/hangouts"
+)
+
+// Config holds everything the action needs to run. It is populated from the
+// action inputs, which GitHub passes to the container as INPUT_<NAME> env vars.
+type Config struct {
+	GithubToken     string
+	GithubEventName string
+	GithubEventPath string
+	WebhookUrl      string
+	// Space and ServiceAccountKey are used to post through the Chat API
+	// instead of a webhook
+	Space             string
+	ServiceAccountKey []byte
+	// Routes send notifications to other destinations than the above
+	Routes         []*Route
+	SelfActionName string
+	// Events to notify, all supported events when empty
+	Events           []string
+	Labels           []string
+	SkipNotifyLabels []string
+	Authors          []string
+	SkipAuthors      []string
+	Branches         []string
+	Drafts           bool
+	// Paths only lets through pull requests changing matching files. It is
+	// nil when every pull request is notified.
+	Paths         *PathMatcher
+	Templates     map[string]*MessageTemplate
+	Mentions      map[string]string
+	GroupChecks   bool
+	WaitForChecks bool
+	LiveChecks    bool
+	SendRetries   int
+	SendTimeout   time.Duration
+	// TruncateMessages cuts messages exceeding the limits of Chat instead of
+	// failing to send them
+	TruncateMessages bool
+	Poll             PollPolicy
+
+	ProtectedBranchesOnly bool
+}
+
+// Env vars that were used to configure the action before it understood
+// inputs. They are still honoured when the corresponding input is not set.
+var legacyInputEnvs = map[string]string{
+	"github_token":      "GITHUB_TOKEN",
+	"self_action_name":  "SELF_ACTION_NAME",
+	"skip_notify_label": "SKIP_NOTIFY_LABEL",
+}
+
+// ConfigError lists every missing or invalid input found while loading the
+// configuration, so that they can be fixed in one go.
+type ConfigError []string
+
+func (e ConfigError) Error() string {
+	return fmt.Sprintf("invalid configuration:\n  %s", strings.Join(e, "\n  "))
+}
+
+func loadConfig() (*Config, error) {
+	in := &inputs{lookup: os.LookupEnv}
+	// The configuration file provides the defaults of the inputs
+	file := in.ConfigFile("config_file")
+	cfg := &Config{
+		GithubToken:      in.Required("github_token"),
+		GithubEventName:  in.RequiredEnv("GITHUB_EVENT_NAME"),
+		GithubEventPath:  in.RequiredEnv("GITHUB_EVENT_PATH"),
+		SelfActionName:   in.Required("self_action_name"),
+		Events:           file.Events,
+		Labels:           file.Filters.Labels,
+		SkipNotifyLabels: in.List("skip_notify_label", file.Filters.SkipLabels),
+		Authors:          file.Filters.Authors,
+		SkipAuthors:      file.Filters.SkipAuthors,
+		Branches:         file.Filters.Branches,
+		Drafts:           file.Filters.Drafts,
+		Templates:        file.Templates,
+		Mentions:         file.Mentions,
+		GroupChecks:      in.Bool("group_checks", file.Checks.Group),
+		WaitForChecks:    in.Bool("wait_for_checks", file.Polling.WaitForChecks),
+		LiveChecks:       in.Bool("live_checks", file.Polling.Live),
+		SendRetries:      in.Int("send_retries", hangouts.DefaultRetryPolicy.MaxRetries),
+		SendTimeout:      in.Duration("send_timeout", hangouts.DefaultTimeout),
+		TruncateMessages: in.Bool("truncate_messages", true),
+		Poll: PollPolicy{
+			Interval:    in.Duration("poll_interval", file.Polling.Interval),
+			MaxInterval: in.Duration("poll_max_interval", file.Polling.MaxInterval),
+			Multiplier:  in.Float("poll_backoff", file.Polling.Backoff),
+			Jitter:      in.Float("poll_jitter", file.Polling.Jitter),
+			Timeout:     in.Duration("poll_timeout", file.Polling.Timeout),
+		},
+
+		ProtectedBranchesOnly: in.Bool("protected_branches_only", false),
+	}
+	cfg.Routes = in.Routes("routes")
+	if cfg.Routes == nil {
+		cfg.Routes = file.Routes
+	}
+	cfg.Paths = in.Paths("paths", "paths_ignore", file.Filters.Paths, file.Filters.PathsIgnore)
+	cfg.Space = spaceName(in.String("space", ""))
+	needsKey := len(cfg.Space) > 0
+	for _, r := range cfg.Routes {
+		needsKey = needsKey || len(r.Space) > 0
+	}
+	if needsKey {
+		key, err := resolveServiceAccountKey()
+		if err != nil {
+			in.errs = append(in.errs, err.Error())
+		}
+		cfg.ServiceAccountKey = key
+	}
+	// Routes bring their own destinations, so the default one is optional
+	if len(cfg.Space) == 0 && len(cfg.Routes) == 0 {
+		webhookUrl, err := resolveWebhookUrl()
+		if err != nil {
+			in.errs = append(in.errs, err.Error())
+		}
+		cfg.WebhookUrl = webhookUrl
+	}
+	if cfg.SendRetries < 0 {
+		in.errs = append(in.errs, "input send_retries must not be negative")
+	}
+	if cfg.SendTimeout <= 0 {
+		in.errs = append(in.errs, "input send_timeout must be positive")
+	}
+	if cfg.Poll.Interval <= 0 {
+		in.errs = append(in.errs, "input poll_interval must be positive")
+	}
+	if cfg.Poll.MaxInterval < cfg.Poll.Interval {
+		in.errs = append(in.errs, "input poll_max_interval must not be less than poll_interval")
+	}
+	if cfg.Poll.Multiplier < 1 {
+		in.errs = append(in.errs, "input poll_backoff must be at least 1")
+	}
+	if cfg.Poll.Jitter < 0 || cfg.Poll.Jitter >= 1 {
+		in.errs = append(in.errs, "input poll_jitter must be at least 0 and less than 1")
+	}
+	if cfg.Poll.Timeout <= 0 {
+		in.errs = append(in.errs, "input poll_timeout must be positive")
+	}
+	if len(in.errs) > 0 {
+		return nil, in.errs
+	}
+	return cfg, nil
+}
+
+func (c *Config) acceptsLabels(labels []string) bool {
+	if containsAnyString(c.SkipNotifyLabels, labels) {
+		return false
+	}
+	return len(c.Labels) == 0 || containsAnyString(c.Labels, labels)
+}
+
+func (c *Config) acceptsAuthor(login string) bool {
+	if containsString(c.SkipAuthors, login) {
+		return false
+	}
+	return len(c.Authors) == 0 || containsString(c.Authors, login)
+}
+
+func (c *Config) acceptsBranch(branch string) bool {
+	return len(c.Branches) == 0 || matchAnyPattern(c.Branches, branch)
+}
+
+// spaceName accepts both the resource name of a space and its id
+func spaceName(space string) string {
+	if len(space) > 0 && !strings.HasPrefix(space, "spaces/") {
+		return "spaces/" + space
+	}
+	return space
+}
+
+// inputs reads action inputs and collects the problems found instead of
+// failing on the first one.
+type inputs struct {
+	lookup func(key string) (string, bool)
+	errs   ConfigError
+}
+
+func inputEnv(name string) string {
+	return "INPUT_" + strings.ToUpper(strings.Replace(name, " ", "_", -1))
+}
+
+func (in *inputs) raw(name string) (string, bool) {
+	if v, ok := in.lookup(inputEnv(name)); ok && len(strings.TrimSpace(v)) > 0 {
+		return strings.TrimSpace(v), true
+	}
+	if key, ok := legacyInputEnvs[name]; ok {
+		if v, ok := in.lookup(key); ok && len(strings.TrimSpace(v)) > 0 {
+			return strings.TrimSpace(v), true
+		}
+	}
+	return "", false
+}
+
+func (in *inputs) invalid(name, v, kind string) {
+	in.errs = append(in.errs, fmt.Sprintf("input %s: %q is not a valid %s", name, v, kind))
+}
+
+func (in *inputs) String(name, def string) string {
+	if v, ok := in.raw(name); ok {
+		return v
+	}
+	return def
+}
+
+func (in *inputs) Required(name string) string {
+	v, ok := in.raw(name)
+	if !ok {
+		in.errs = append(in.errs, fmt.Sprintf("input %s is required", name))
+	}
+	return v
+}
+
+func (in *inputs) RequiredEnv(key string) string {
+	v, ok := in.lookup(key)
+	if !ok || len(v) == 0 {
+		in.errs = append(in.errs, fmt.Sprintf("environment variable %s not provided", key))
+	}
+	return v
+}
+
+func (in *inputs) Bool(name string, def bool) bool {
+	v, ok := in.raw(name)
+	if !ok {
+		return def
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		in.invalid(name, v, "boolean")
+		return def
+	}
+	return b
+}
+
+func (in *inputs) Duration(name string, def time.Duration) time.Duration {
+	v, ok := in.raw(name)
+	if !ok {
+		return def
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		in.invalid(name, v, "duration")
+		return def
+	}
+	return d
+}
+
+func (in *inputs) Int(name string, def int) int {
+	v, ok := in.raw(name)
+	if !ok {
+		return def
+	}
+	i, err := strconv.Atoi(v)
+	if err != nil {
+		in.invalid(name, v, "integer")
+		return def
+	}
+	return i
+}
+
+func (in *inputs) Float(name string, def float64) float64 {
+	v, ok := in.raw(name)
+	if !ok {
+		return def
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		in.invalid(name, v, "number")
+		return def
+	}
+	return f
+}
+
+// Routes parses a JSON array of routes and validates each of them
+func (in *inputs) Routes(name string) []*Route {
+	v, ok := in.raw(name)
+	if !ok {
+		return nil
+	}
+	var routes []*Route
+	// Unknown fields are rejected, as a misspelled filter would otherwise
+	// route every notification
+	dec := json.NewDecoder(strings.NewReader(v))
+	dec.DisallowUnknownFields()
+	err := dec.Decode(&routes)
+	if err == nil && dec.More() {
+		err = fmt.Errorf("unexpected data after the array")
+	}
+	if err != nil {
+		// The value is not echoed as routes usually contain webhook urls
+		in.errs = append(in.errs, fmt.Sprintf("input %s is not a valid JSON array of routes: %v", name, err))
+		return nil
+	}
+	for i, r := range routes {
+		if len(r.Name) == 0 {
+			r.Name = fmt.Sprintf("#%d", i+1)
+		}
+		if err := r.validate(); err != nil {
+			in.errs = append(in.errs, fmt.Sprintf("input %s: %v", name, err))
+		}
+	}
+	return routes
+}
+
+// Paths compiles the include and exclude path patterns, which are lists
+func (in *inputs) Paths(include, exclude string, defInclude, defExclude []string) *PathMatcher {
+	inc, exc := in.List(include, defInclude), in.List(exclude, defExclude)
+	if len(inc) == 0 && len(exc) == 0 {
+		return nil
+	}
+	m, err := NewPathMatcher(inc, exc)
+	if err != nil {
+		in.errs = append(in.errs, fmt.Sprintf("input %s or %s: %v", include, exclude, err))
+		return nil
+	}
+	return m
+}
+
+// List accepts comma or newline separated values, which allows both inline
+// and YAML block style inputs in the workflow.
+func (in *inputs) List(name string, def []string) []string {
+	v, ok := in.raw(name)
+	if !ok {
+		return def
+	}
+	var list []string
+	for _, item := range strings.FieldsFunc(v, func(r rune) bool { return r == ',' || r == '\n' }) {
+		if item = strings.TrimSpace(item); len(item) > 0 {
+			list = append(list, item)
+		}
+	}
+	return list
+}
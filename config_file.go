@@ -0,0 +1,316 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Configuration file read when the config_file input is not set. Relative
+// paths are resolved against the workspace, where the repository is checked
+// out.
+const defaultConfigFile = ".github/hangouts.yml"
+
+// ConfigFile is the configuration a repository can commit to tune its
+// notifications without rebuilding the image. Inputs set in the workflow take
+// precedence over it.
+type ConfigFile struct {
+	// Events to notify, all supported events when empty
+	Events  []string    `yaml:"events"`
+	Filters FileFilters `yaml:"filters"`
+	Routes  []*Route    `yaml:"routes"`
+	// Templates are keyed by message kind, see defaultTemplates
+	Templates map[string]*MessageTemplate `yaml:"templates"`
+	// Mentions maps GitHub logins to Chat users, e.g. users/123456789
+	Mentions map[string]string `yaml:"mentions"`
+	Checks   FileChecks        `yaml:"checks"`
+	Polling  FilePolling       `yaml:"polling"`
+}
+
+// FileFilters select the pull requests, issues, pushes and releases that are
+// notified at all. Routes filter further.
+type FileFilters struct {
+	// Labels only lets through pull requests and issues with any of them
+	Labels     []string `yaml:"labels"`
+	SkipLabels []string `yaml:"skip_labels"`
+	// Authors only lets through notifications about their work
+	Authors     []string `yaml:"authors"`
+	SkipAuthors []string `yaml:"skip_authors"`
+	// Branches are glob patterns of base branches and pushed branches
+	Branches []string `yaml:"branches"`
+	// Drafts tells whether draft pull requests are notified
+	Drafts      bool     `yaml:"drafts"`
+	Paths       []string `yaml:"paths"`
+	PathsIgnore []string `yaml:"paths_ignore"`
+}
+
+// FileChecks tunes the checks card
+type FileChecks struct {
+	// Group lists the checks by GitHub App or commit status prefix
+	Group bool `yaml:"group"`
+}
+
+type FilePolling struct {
+	WaitForChecks bool          `yaml:"wait_for_checks"`
+	Live          bool          `yaml:"live"`
+	Interval      time.Duration `yaml:"interval"`
+	MaxInterval   time.Duration `yaml:"max_interval"`
+	Backoff       float64       `yaml:"backoff"`
+	Jitter        float64       `yaml:"jitter"`
+	Timeout       time.Duration `yaml:"timeout"`
+}
+
+func defaultConfigFileValues() *ConfigFile {
+	return &ConfigFile{
+		Filters: FileFilters{
+			Drafts: true,
+		},
+		Polling: FilePolling{
+			WaitForChecks: true,
+			Interval:      15 * time.Second,
+			MaxInterval:   2 * time.Minute,
+			Backoff:       1.5,
+			Jitter:        0.2,
+			Timeout:       time.Hour,
+		},
+	}
+}
+
+// ConfigFile reads the configuration file named by the input. A missing file
+// is only an error when the input names another file than the default one.
+func (in *inputs) ConfigFile(name string) *ConfigFile {
+	file, explicit := in.raw(name)
+	if !explicit || path.Clean(file) == defaultConfigFile {
+		file, explicit = defaultConfigFile, false
+	}
+	p := file
+	if !filepath.IsAbs(p) {
+		if ws, ok := in.lookup("GITHUB_WORKSPACE"); ok && len(ws) > 0 {
+			p = filepath.Join(ws, p)
+		}
+	}
+	data, err := ioutil.ReadFile(p)
+	if os.IsNotExist(err) && !explicit {
+		return defaultConfigFileValues()
+	}
+	if err != nil {
+		in.errs = append(in.errs, fmt.Sprintf("input %s: cannot read configuration file: %v", name, err))
+		return defaultConfigFileValues()
+	}
+	c, errs := parseConfigFile(file, data)
+	in.errs = append(in.errs, errs...)
+	return c
+}
+
+var yamlLineError = regexp.MustCompile(`^(?:yaml: )?line (\d+): `)
+
+// Problems of the YAML parser, as opposed to its scanner, are reported with
+// the zero based line of the token or of the collection they are found in,
+// e.g. the line of the [ of a list missing its ]
+var yamlParserProblems = map[string]bool{
+	"did not find expected <stream-start>":   true,
+	"did not find expected <document start>": true,
+	"found undefined tag handle":             true,
+	"did not find expected node content":     true,
+	"did not find expected '-' indicator":    true,
+	"did not find expected key":              true,
+	"did not find expected ',' or ']'":       true,
+	"did not find expected ',' or '}'":       true,
+	"found duplicate %YAML directive":        true,
+	"found incompatible YAML document":       true,
+	"found duplicate %TAG directive":         true,
+}
+
+// yamlError formats an error of the YAML decoder as file:line: message
+func yamlError(file, msg string) string {
+	line := 0
+	if m := yamlLineError.FindStringSubmatch(msg); m != nil {
+		line, _ = strconv.Atoi(m[1])
+		msg = msg[len(m[0]):]
+	}
+	msg = strings.TrimPrefix(msg, "yaml: ")
+	if yamlParserProblems[msg] {
+		// Zero based, and left out for the first line
+		line++
+	}
+	if line == 0 {
+		return fmt.Sprintf("%s: %s", file, msg)
+	}
+	return fmt.Sprintf("%s:%d: %s", file, line, msg)
+}
+
+// parseConfigFile decodes and validates the file. Every problem is reported
+// as file:line: message.
+func parseConfigFile(file string, data []byte) (*ConfigFile, []string) {
+	c := defaultConfigFileValues()
+	var errs []string
+	yamlErrorf := func(msg string) {
+		errs = append(errs, yamlError(file, msg))
+	}
+
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(c); err != nil {
+		switch err := err.(type) {
+		case *yaml.TypeError:
+			for _, e := range err.Errors {
+				yamlErrorf(e)
+			}
+		default:
+			if err.Error() != "EOF" {
+				yamlErrorf(err.Error())
+			}
+			// Nothing to validate in an empty or unparsable file
+			return c, errs
+		}
+	}
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil || len(root.Content) == 0 {
+		return c, errs
+	}
+	v := &configFileValidator{file: file, root: root.Content[0]}
+	v.validate(c)
+	return c, append(errs, v.errs...)
+}
+
+// configFileValidator checks the decoded values and reports the line of the
+// offending value, found by walking the document along the same path.
+type configFileValidator struct {
+	file string
+	root *yaml.Node
+	errs []string
+}
+
+// errorf reports a problem at the node reached by following path, a list of
+// mapping keys and sequence indices. It falls back to the closest node found.
+func (v *configFileValidator) errorf(path []interface{}, format string, args ...interface{}) {
+	node := v.root
+walk:
+	for _, p := range path {
+		switch p := p.(type) {
+		case string:
+			if node.Kind != yaml.MappingNode {
+				break walk
+			}
+			found := false
+			for i := 0; i+1 < len(node.Content); i += 2 {
+				if node.Content[i].Value == p {
+					node, found = node.Content[i+1], true
+					break
+				}
+			}
+			if !found {
+				break walk
+			}
+		case int:
+			if node.Kind != yaml.SequenceNode || p >= len(node.Content) {
+				break walk
+			}
+			node = node.Content[p]
+		}
+	}
+	v.errs = append(v.errs, fmt.Sprintf("%s:%d: %s", v.file, node.Line, fmt.Sprintf(format, args...)))
+}
+
+func (v *configFileValidator) validate(c *ConfigFile) {
+	for i, e := range c.Events {
+		if !containsString(supportedEvents, e) {
+			v.errorf([]interface{}{"events", i}, "unsupported event %q", e)
+		}
+	}
+	for i, b := range c.Filters.Branches {
+		if _, err := path.Match(b, ""); err != nil {
+			v.errorf([]interface{}{"filters", "branches", i}, "invalid pattern %q", b)
+		}
+	}
+	for _, key := range []string{"paths", "paths_ignore"} {
+		patterns := c.Filters.Paths
+		if key == "paths_ignore" {
+			patterns = c.Filters.PathsIgnore
+		}
+		for i, p := range patterns {
+			if _, err := compilePathPattern(p); err != nil {
+				v.errorf([]interface{}{"filters", key, i}, "%v", err)
+			}
+		}
+	}
+	for i, r := range c.Routes {
+		if r == nil {
+			v.errorf([]interface{}{"routes", i}, "empty route")
+			continue
+		}
+		if len(r.Name) == 0 {
+			r.Name = fmt.Sprintf("#%d", i+1)
+		}
+		if err := r.validate(); err != nil {
+			v.errorf([]interface{}{"routes", i}, "%v", err)
+		}
+	}
+	// Maps are walked in order so that problems are reported in a stable order
+	var kinds []string
+	for kind := range c.Templates {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+	for _, kind := range kinds {
+		t := c.Templates[kind]
+		if _, ok := defaultTemplates[kind]; !ok {
+			v.errorf([]interface{}{"templates", kind}, "unknown message kind %q", kind)
+			continue
+		}
+		if t == nil {
+			v.errorf([]interface{}{"templates", kind}, "empty template")
+			continue
+		}
+		errs := t.parse()
+		for _, field := range []string{"title", "subtitle", "text", "message"} {
+			if err, ok := errs[field]; ok {
+				v.errorf([]interface{}{"templates", kind, field}, "%v", err)
+			}
+		}
+	}
+	var logins []string
+	for login := range c.Mentions {
+		logins = append(logins, login)
+	}
+	sort.Strings(logins)
+	for _, login := range logins {
+		user := c.Mentions[login]
+		if !strings.HasPrefix(user, "users/") {
+			v.errorf([]interface{}{"mentions", login}, "chat user %q of %s is not in the form users/<id>", user, login)
+		}
+	}
+	p := c.Polling
+	n := len(v.errs)
+	if p.Interval <= 0 {
+		v.errorf([]interface{}{"polling", "interval"}, "interval must be positive")
+	}
+	if p.MaxInterval < p.Interval {
+		v.errorf([]interface{}{"polling", "max_interval"}, "max_interval must not be less than interval")
+	}
+	if p.Backoff < 1 {
+		v.errorf([]interface{}{"polling", "backoff"}, "backoff must be at least 1")
+	}
+	if p.Jitter < 0 || p.Jitter >= 1 {
+		v.errorf([]interface{}{"polling", "jitter"}, "jitter must be at least 0 and less than 1")
+	}
+	if p.Timeout <= 0 {
+		v.errorf([]interface{}{"polling", "timeout"}, "timeout must be positive")
+	}
+	if len(v.errs) > n {
+		// Keep the inputs from reporting the same problems again
+		def := defaultConfigFileValues().Polling
+		c.Polling.Interval, c.Polling.MaxInterval = def.Interval, def.MaxInterval
+		c.Polling.Backoff, c.Polling.Jitter, c.Polling.Timeout = def.Backoff, def.Jitter, def.Timeout
+	}
+}
@@ -0,0 +1,287 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseConfigFile(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		errs []string
+	}{
+		{
+			name: "empty",
+			data: "",
+		},
+		{
+			name: "valid",
+			data: `events: [pull_request, push]
+filters:
+  branches: [master, release-*]
+  paths: [/src/]
+templates:
+  pull_request.merged:
+    title: Shipped
+mentions:
+  octocat: users/123
+polling:
+  interval: 5s
+`,
+		},
+		{
+			name: "unknown key",
+			data: "events: [push]\nfilter:\n  labels: [bug]\n",
+			errs: []string{"hangouts.yml:2: field filter not found in type main.ConfigFile"},
+		},
+		{
+			name: "syntax error in a list",
+			data: "events: [push]\nfilters:\n  skip_authors: [dependabot[bot]]\n",
+			errs: []string{"hangouts.yml:3: did not find expected ',' or ']'"},
+		},
+		{
+			name: "unclosed map",
+			data: "events: [push]\n\nmentions: {octocat: users/1\npolling: {}\n",
+			errs: []string{"hangouts.yml:3: did not find expected ',' or '}'"},
+		},
+		{
+			name: "syntax error in a block",
+			data: "events: [push]\nfilters:\n  skip_authors: a: b\n",
+			errs: []string{"hangouts.yml:3: mapping values are not allowed in this context"},
+		},
+		{
+			name: "wrong type",
+			data: "polling:\n  interval: soon\n",
+			errs: []string{`hangouts.yml:2: cannot unmarshal !!str ` + "`soon`" + ` into time.Duration`},
+		},
+		{
+			name: "invalid values",
+			data: `events:
+  - push
+  - deployment
+filters:
+  branches: ["rel[ease"]
+  paths_ignore: ["/"]
+mentions:
+  octocat: "123"
+polling:
+  backoff: 0.5
+`,
+			errs: []string{
+				`hangouts.yml:3: unsupported event "deployment"`,
+				`hangouts.yml:5: invalid pattern "rel[ease"`,
+				`hangouts.yml:6: invalid path pattern "/"`,
+				`hangouts.yml:8: chat user "123" of octocat is not in the form users/<id>`,
+				`hangouts.yml:10: backoff must be at least 1`,
+			},
+		},
+		{
+			name: "templates",
+			data: `templates:
+  pull_request.shipped:
+    title: Shipped
+  pull_request.merged:
+    title: "{{.PullRequest.Title"
+  issues.opened:
+    message: '{"text": "{{.Issue.Title}}"}'
+`,
+			errs: []string{
+				`hangouts.yml:7: {{.Issue.Title}} prints a value that is not encoded, use json, e.g. {{json .PullRequest.Title}}`,
+				`hangouts.yml:5: template: title:1: unclosed action`,
+				`hangouts.yml:3: unknown message kind "pull_request.shipped"`,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, errs := parseConfigFile("hangouts.yml", []byte(tt.data))
+			if !sameStrings(errs, tt.errs) {
+				t.Errorf("errors = %q, want %q", errs, tt.errs)
+			}
+		})
+	}
+}
+
+func TestParseConfigFileReadme(t *testing.T) {
+	readme, err := ioutil.ReadFile("README.md")
+	if err != nil {
+		t.Fatal(err)
+	}
+	section := strings.SplitN(string(readme), "### Configuration file", 2)[1]
+	example := strings.SplitN(strings.SplitN(section, "```yaml\n", 2)[1], "```", 2)[0]
+	defer setenv(t, map[string]string{
+		"RELEASE_ROOM_WEBHOOK_URL": "https://chat.googleapis.com/v1/spaces/x/messages?key=k&token=t",
+	})()
+	if _, errs := parseConfigFile("hangouts.yml", []byte(example)); len(errs) > 0 {
+		t.Errorf("example of the README is invalid: %q", errs)
+	}
+}
+
+func TestParseConfigFileDefaults(t *testing.T) {
+	c, errs := parseConfigFile("hangouts.yml", []byte("polling:\n  interval: 5s\n  timeout: -1s\n"))
+	if len(errs) != 1 {
+		t.Fatalf("errors = %q, want the timeout only", errs)
+	}
+	want := defaultConfigFileValues().Polling
+	if !reflect.DeepEqual(c.Polling, want) {
+		t.Errorf("invalid polling = %+v, want the defaults %+v", c.Polling, want)
+	}
+	if !c.Filters.Drafts {
+		t.Errorf("drafts are notified by default")
+	}
+}
+
+func TestLoadConfigPrecedence(t *testing.T) {
+	ws, err := ioutil.TempDir("", "hangouts-action")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(ws)
+	file := `filters:
+  skip_labels: [wip]
+checks:
+  group: true
+polling:
+  wait_for_checks: false
+  interval: 5s
+  timeout: 10m
+`
+	if err := os.MkdirAll(filepath.Join(ws, ".github"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(ws, defaultConfigFile), []byte(file), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		env   map[string]string
+		check func(t *testing.T, cfg *Config)
+	}{
+		{
+			name: "file values",
+			check: func(t *testing.T, cfg *Config) {
+				if !cfg.GroupChecks || cfg.WaitForChecks || cfg.Poll.Interval != 5*time.Second || cfg.Poll.Timeout != 10*time.Minute {
+					t.Errorf("file values not applied: %+v", cfg)
+				}
+				if !reflect.DeepEqual(cfg.SkipNotifyLabels, []string{"wip"}) {
+					t.Errorf("skip labels = %q, want the file ones", cfg.SkipNotifyLabels)
+				}
+				if cfg.Poll.MaxInterval != 2*time.Minute {
+					t.Errorf("max interval = %v, want the default", cfg.Poll.MaxInterval)
+				}
+			},
+		},
+		{
+			name: "inputs override the file",
+			env: map[string]string{
+				"INPUT_GROUP_CHECKS":      "false",
+				"INPUT_POLL_INTERVAL":     "1m",
+				"INPUT_SKIP_NOTIFY_LABEL": "draft, do-not-notify",
+			},
+			check: func(t *testing.T, cfg *Config) {
+				if cfg.GroupChecks || cfg.Poll.Interval != time.Minute || cfg.Poll.Timeout != 10*time.Minute {
+					t.Errorf("inputs not applied: %+v", cfg)
+				}
+				if !reflect.DeepEqual(cfg.SkipNotifyLabels, []string{"draft", "do-not-notify"}) {
+					t.Errorf("skip labels = %q, want the input ones", cfg.SkipNotifyLabels)
+				}
+			},
+		},
+		{
+			name: "default file named explicitly",
+			env:  map[string]string{"INPUT_CONFIG_FILE": defaultConfigFile},
+			check: func(t *testing.T, cfg *Config) {
+				if !cfg.GroupChecks {
+					t.Errorf("file values not applied: %+v", cfg)
+				}
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := map[string]string{
+				"GITHUB_WORKSPACE":       ws,
+				"GITHUB_EVENT_NAME":      "pull_request",
+				"GITHUB_EVENT_PATH":      "event.json",
+				"INPUT_GITHUB_TOKEN":     "token",
+				"INPUT_SELF_ACTION_NAME": "Hangouts",
+				"INPUT_WEBHOOK_URL":      "https://chat.googleapis.com/v1/spaces/x/messages?key=k&token=t",
+			}
+			for k, v := range tt.env {
+				env[k] = v
+			}
+			defer setenv(t, env)()
+			cfg, err := loadConfig()
+			if err != nil {
+				t.Fatal(err)
+			}
+			tt.check(t, cfg)
+		})
+	}
+}
+
+func TestLoadConfigMissingFile(t *testing.T) {
+	ws, err := ioutil.TempDir("", "hangouts-action")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(ws)
+	env := map[string]string{
+		"GITHUB_WORKSPACE":       ws,
+		"GITHUB_EVENT_NAME":      "pull_request",
+		"GITHUB_EVENT_PATH":      "event.json",
+		"INPUT_GITHUB_TOKEN":     "token",
+		"INPUT_SELF_ACTION_NAME": "Hangouts",
+		"INPUT_WEBHOOK_URL":      "https://chat.googleapis.com/v1/spaces/x/messages?key=k&token=t",
+	}
+	defer setenv(t, env)()
+	if _, err := loadConfig(); err != nil {
+		t.Errorf("missing default file: %v", err)
+	}
+
+	os.Setenv("INPUT_CONFIG_FILE", "other.yml")
+	if _, err := loadConfig(); err == nil {
+		t.Errorf("missing file named by the input did not fail")
+	}
+}
+
+// setenv sets the env vars, clearing the other inputs, and returns a func
+// restoring the environment
+func setenv(t *testing.T, env map[string]string) func() {
+	saved := os.Environ()
+	for _, kv := range saved {
+		k := strings.SplitN(kv, "=", 2)[0]
+		if strings.HasPrefix(k, "INPUT_") {
+			os.Unsetenv(k)
+		}
+	}
+	for _, k := range legacyInputEnvs {
+		os.Unsetenv(k)
+	}
+	for k, v := range env {
+		if err := os.Setenv(k, v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return func() {
+		os.Clearenv()
+		for _, kv := range saved {
+			parts := strings.SplitN(kv, "=", 2)
+			os.Setenv(parts[0], parts[1])
+		}
+	}
+}
+
+func sameStrings(a, b []string) bool {
+	if len(a) == 0 && len(b) == 0 {
+		return true
+	}
+	return reflect.DeepEqual(a, b)
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+
+	"github.com/google/go-github/v28/github"
+)
+
+// EventNotifier sends the notifications for an event. The event is the
+// decoded payload, e.g. *github.PushEvent for push events.
+type EventNotifier func(ctx context.Context, event interface{}) error
+
+// Events the action can notify
+var supportedEvents = []string{
+	"pull_request",
+	"pull_request_review",
+	"pull_request_review_comment",
+	"push",
+	"release",
+	"issues",
+	"workflow_run",
+	"check_suite",
+}
+
+// Events that go-github does not know about. Any other event is decoded by
+// github.ParseWebHook into its go-github type.
+var localEventTypes = map[string]func() interface{}{
+	"workflow_run": func() interface{} { return &WorkflowRunEvent{} },
+}
+
+// WorkflowRunEvent is triggered when a GitHub Actions workflow run is
+// requested or completed. The go-github version in use predates workflow
+// runs, so only the fields needed for notifications are decoded.
+type WorkflowRunEvent struct {
+	Action      *string            `json:"action,omitempty"`
+	WorkflowRun *WorkflowRun       `json:"workflow_run,omitempty"`
+	Repo        *github.Repository `json:"repository,omitempty"`
+	Sender      *github.User       `json:"sender,omitempty"`
+}
+
+type WorkflowRun struct {
+	ID           *int64                `json:"id,omitempty"`
+	Name         *string               `json:"name,omitempty"`
+	Event        *string               `json:"event,omitempty"`
+	HeadBranch   *string               `json:"head_branch,omitempty"`
+	HeadSHA      *string               `json:"head_sha,omitempty"`
+	Status       *string               `json:"status,omitempty"`
+	Conclusion   *string               `json:"conclusion,omitempty"`
+	HTMLURL      *string               `json:"html_url,omitempty"`
+	PullRequests []*github.PullRequest `json:"pull_requests,omitempty"`
+}
+
+// Handle registers the notifier for the given GitHub event name, replacing
+// any notifier registered earlier for the same event.
+func (a *HangoutsAction) Handle(eventName string, notifier EventNotifier) {
+	if a.notifiers == nil {
+		a.notifiers = make(map[string]EventNotifier)
+	}
+	a.notifiers[eventName] = notifier
+}
+
+// Dispatch decodes the event payload and passes it to the notifier registered
+// for the event. Events without a notifier are logged and ignored.
+func (a *HangoutsAction) Dispatch(ctx context.Context, eventName string, payload []byte) error {
+	notifier, ok := a.notifiers[eventName]
+	if !ok {
+		log.Printf("unsupported event %q, nothing to notify", eventName)
+		return nil
+	}
+	event, err := decodeEvent(eventName, payload)
+	if err != nil {
+		return err
+	}
+	return notifier(ctx, event)
+}
+
+func decodeEvent(eventName string, payload []byte) (interface{}, error) {
+	newEvent, ok := localEventTypes[eventName]
+	if !ok {
+		event, err := github.ParseWebHook(eventName, payload)
+		if err != nil {
+			return nil, fmt.Errorf("cannot decode %s event: %v", eventName, err)
+		}
+		return event, nil
+	}
+	event := newEvent()
+	if err := json.Unmarshal(payload, event); err != nil {
+		return nil, fmt.Errorf("cannot decode %s event: %v", eventName, err)
+	}
+	return event, nil
+}
@@ -1,8 +1,9 @@
 module github.com/mirage20/hangouts-action
 
-go 1.12
+go 1.13
 
 require (
 	github.com/google/go-github/v28 v28.1.1
 	golang.org/x/oauth2 v0.0.0-20180821212333-d2e6202438be
+	gopkg.in/yaml.v3 v3.0.1
 )
@@ -0,0 +1,251 @@
+package hangouts
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/jwt"
+)
+
+const (
+	// DefaultBaseURL is the endpoint of the Chat REST API
+	DefaultBaseURL = "https://chat.googleapis.com/v1/"
+	// ScopeChatBot is the OAuth scope of a bot calling the Chat API
+	ScopeChatBot = "https://www.googleapis.com/auth/chat.bot"
+
+	defaultTokenURL = "https://oauth2.googleapis.com/token"
+)
+
+// APIClient calls the Chat REST API as a bot, authenticated with the key of a
+// Google Cloud service account. Unlike a webhook, it can post to any space the
+// bot is a member of.
+type APIClient struct {
+	*http.Client
+	BaseURL string
+	Retry   RetryPolicy
+	Check   MessageCheck
+}
+
+// serviceAccountKey has the fields of a service account JSON key file needed
+// to get tokens
+type serviceAccountKey struct {
+	Type         string `json:"type"`
+	ClientEmail  string `json:"client_email"`
+	PrivateKey   string `json:"private_key"`
+	PrivateKeyID string `json:"private_key_id"`
+	TokenURI     string `json:"token_uri"`
+}
+
+// NewAPIClient creates a client authenticated with the given service account
+// JSON key. Tokens are requested with the same transport and timeout as the
+// API calls.
+func NewAPIClient(ctx context.Context, serviceAccountJSON []byte, opts ...ClientOption) (*APIClient, error) {
+	var key serviceAccountKey
+	if err := json.Unmarshal(serviceAccountJSON, &key); err != nil {
+		// The syntax error does not contain the key, but the caller may log it
+		return nil, fmt.Errorf("invalid service account key: %v", err)
+	}
+	if key.Type != "service_account" || len(key.ClientEmail) == 0 || len(key.PrivateKey) == 0 {
+		return nil, fmt.Errorf("invalid service account key: not a service account key file")
+	}
+	if len(key.TokenURI) == 0 {
+		key.TokenURI = defaultTokenURL
+	}
+	c := &APIClient{
+		Client:  &http.Client{Timeout: DefaultTimeout},
+		BaseURL: DefaultBaseURL,
+		Retry:   DefaultRetryPolicy,
+	}
+	cfg := &clientConfig{client: c.Client, retry: &c.Retry, check: &c.Check}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	conf := &jwt.Config{
+		Email:        key.ClientEmail,
+		PrivateKey:   []byte(key.PrivateKey),
+		PrivateKeyID: key.PrivateKeyID,
+		Scopes:       []string{ScopeChatBot},
+		TokenURL:     key.TokenURI,
+	}
+	tokenClient := &http.Client{Transport: c.Client.Transport, Timeout: c.Client.Timeout}
+	c.Client.Transport = &oauth2.Transport{
+		Source: conf.TokenSource(context.WithValue(ctx, oauth2.HTTPClient, tokenClient)),
+		Base:   c.Client.Transport,
+	}
+	return c, nil
+}
+
+// ListSpaces returns a page of the spaces the bot is a member of. An empty
+// pageToken returns the first page.
+func (c *APIClient) ListSpaces(ctx context.Context, pageSize int, pageToken string) (*ListSpacesResponse, error) {
+	resp := &ListSpacesResponse{}
+	err := c.do(ctx, http.MethodGet, "spaces", pageQuery(pageSize, pageToken), nil, resp)
+	if err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// ListAllSpaces pages through all spaces the bot is a member of
+func (c *APIClient) ListAllSpaces(ctx context.Context) ([]*Space, error) {
+	var spaces []*Space
+	pageToken := ""
+	for {
+		resp, err := c.ListSpaces(ctx, 0, pageToken)
+		if err != nil {
+			return nil, err
+		}
+		spaces = append(spaces, resp.Spaces...)
+		if len(resp.NextPageToken) == 0 {
+			return spaces, nil
+		}
+		pageToken = resp.NextPageToken
+	}
+}
+
+// ListMemberships returns a page of the memberships of the space, which is
+// given by its resource name, e.g. "spaces/AAAAMpdlehY".
+func (c *APIClient) ListMemberships(ctx context.Context, space string, pageSize int, pageToken string) (*ListMembershipsResponse, error) {
+	resp := &ListMembershipsResponse{}
+	err := c.do(ctx, http.MethodGet, space+"/members", pageQuery(pageSize, pageToken), nil, resp)
+	if err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// ListAllMemberships pages through all memberships of the space
+func (c *APIClient) ListAllMemberships(ctx context.Context, space string) ([]*Membership, error) {
+	var memberships []*Membership
+	pageToken := ""
+	for {
+		resp, err := c.ListMemberships(ctx, space, 0, pageToken)
+		if err != nil {
+			return nil, err
+		}
+		memberships = append(memberships, resp.Memberships...)
+		if len(resp.NextPageToken) == 0 {
+			return memberships, nil
+		}
+		pageToken = resp.NextPageToken
+	}
+}
+
+// CreateMessage posts msg to the space. The options set the thread, the
+// request ID and such.
+func (c *APIClient) CreateMessage(ctx context.Context, space string, msg *Message, opts ...SendOption) (*Message, error) {
+	msg, err := c.Check.prepare(msg)
+	if err != nil {
+		return nil, err
+	}
+	q := url.Values{}
+	for _, opt := range opts {
+		opt(q)
+	}
+	rMsg := &Message{}
+	if err := c.do(ctx, http.MethodPost, space+"/messages", q, msg, rMsg); err != nil {
+		return nil, err
+	}
+	return rMsg, nil
+}
+
+// GetMessage returns the message with the given resource name, e.g.
+// "spaces/AAAAMpdlehY/messages/UMxbHmzDlr4.UMxbHmzDlr4".
+func (c *APIClient) GetMessage(ctx context.Context, name string) (*Message, error) {
+	rMsg := &Message{}
+	if err := c.do(ctx, http.MethodGet, name, nil, nil, rMsg); err != nil {
+		return nil, err
+	}
+	return rMsg, nil
+}
+
+// UpdateMessage replaces the fields listed in updateMask (e.g. "text",
+// "cards") of the message with the given resource name.
+func (c *APIClient) UpdateMessage(ctx context.Context, name string, msg *Message, updateMask ...string) (*Message, error) {
+	if len(updateMask) == 0 {
+		return nil, fmt.Errorf("update message error: empty update mask")
+	}
+	msg, err := c.Check.prepare(msg)
+	if err != nil {
+		return nil, err
+	}
+	q := url.Values{}
+	q.Set("updateMask", strings.Join(updateMask, ","))
+	rMsg := &Message{}
+	if err := c.do(ctx, http.MethodPut, name, q, msg, rMsg); err != nil {
+		return nil, err
+	}
+	return rMsg, nil
+}
+
+// DeleteMessage deletes the message with the given resource name
+func (c *APIClient) DeleteMessage(ctx context.Context, name string) error {
+	return c.do(ctx, http.MethodDelete, name, nil, nil, nil)
+}
+
+// Space returns a Sender posting to the given space, which makes the API
+// client interchangeable with a webhook Client.
+func (c *APIClient) Space(space string) *SpaceClient {
+	return &SpaceClient{api: c, Space: space}
+}
+
+func (c *APIClient) do(ctx context.Context, method, resource string, q url.Values, in, out interface{}) error {
+	u, err := url.Parse(strings.TrimSuffix(c.BaseURL, "/") + "/" + strings.TrimPrefix(resource, "/"))
+	if err != nil {
+		return err
+	}
+	u.RawQuery = q.Encode()
+	return doJSON(ctx, c.Client, c.Retry, method, u.String(), in, out)
+}
+
+func pageQuery(pageSize int, pageToken string) url.Values {
+	q := url.Values{}
+	if pageSize > 0 {
+		q.Set("pageSize", strconv.Itoa(pageSize))
+	}
+	if len(pageToken) > 0 {
+		q.Set("pageToken", pageToken)
+	}
+	return q
+}
+
+// SpaceClient posts messages to a single space through the Chat API
+type SpaceClient struct {
+	api   *APIClient
+	Space string
+
+	messages messageNames
+}
+
+func (s *SpaceClient) Send(threadKey string, msg *Message) (*Message, error) {
+	return s.SendContext(context.Background(), threadKey, msg)
+}
+
+func (s *SpaceClient) SendContext(ctx context.Context, threadKey string, msg *Message) (*Message, error) {
+	if len(threadKey) == 0 {
+		return s.api.CreateMessage(ctx, s.Space, msg)
+	}
+	return s.api.CreateMessage(ctx, s.Space, msg, WithThreadKey(threadKey), WithMessageReplyOption(ReplyMessageFallbackToNewThread))
+}
+
+func (s *SpaceClient) Update(name string, msg *Message, updateMask ...string) (*Message, error) {
+	return s.UpdateContext(context.Background(), name, msg, updateMask...)
+}
+
+func (s *SpaceClient) UpdateContext(ctx context.Context, name string, msg *Message, updateMask ...string) (*Message, error) {
+	return s.api.UpdateMessage(ctx, name, msg, updateMask...)
+}
+
+func (s *SpaceClient) Upsert(id, threadKey string, msg *Message, updateMask ...string) (*Message, error) {
+	return s.UpsertContext(context.Background(), id, threadKey, msg, updateMask...)
+}
+
+func (s *SpaceClient) UpsertContext(ctx context.Context, id, threadKey string, msg *Message, updateMask ...string) (*Message, error) {
+	return s.messages.upsert(ctx, s, id, threadKey, msg, updateMask)
+}
@@ -0,0 +1,285 @@
+package hangouts
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Styles of the image of a card header
+const (
+	ImageStyleImage  = "IMAGE"
+	ImageStyleAvatar = "AVATAR"
+)
+
+// CardError lists the problems found while building a card
+type CardError []string
+
+func (e CardError) Error() string {
+	return fmt.Sprintf("invalid card: %s", strings.Join(e, "; "))
+}
+
+// CardBuilder composes a card with chained calls:
+//
+//	card, err := hangouts.NewCard().
+//	    Header("Checks", "owner/repo#1").
+//	    Section("Summary").
+//	    KeyValue("Status", "2/3 passed").Link(url).
+//	    Button("View pull request", url).
+//	    Build()
+//
+// Widgets are added to the last section. Calls that cannot be applied, e.g.
+// a link without a key value or an image to follow, are recorded and reported
+// by Build, along with the problems Message.Validate finds in the card.
+type CardBuilder struct {
+	card *Card
+	errs CardError
+}
+
+func NewCard() *CardBuilder {
+	return &CardBuilder{card: &Card{}}
+}
+
+func (b *CardBuilder) errorf(format string, args ...interface{}) *CardBuilder {
+	b.errs = append(b.errs, fmt.Sprintf(format, args...))
+	return b
+}
+
+// Name sets the name of the card, used to tell the cards of a message apart
+func (b *CardBuilder) Name(name string) *CardBuilder {
+	b.card.Name = name
+	return b
+}
+
+func (b *CardBuilder) Header(title, subtitle string) *CardBuilder {
+	if b.card.Header == nil {
+		b.card.Header = &CardHeader{}
+	}
+	b.card.Header.Title = title
+	b.card.Header.Subtitle = subtitle
+	return b
+}
+
+// HeaderImage sets the image of the header, with ImageStyleImage or
+// ImageStyleAvatar
+func (b *CardBuilder) HeaderImage(url, style string) *CardBuilder {
+	if b.card.Header == nil {
+		return b.errorf("card.header.imageUrl: Header must be called first")
+	}
+	switch style {
+	case ImageStyleImage, ImageStyleAvatar:
+	default:
+		return b.errorf("card.header.imageStyle: unknown style %q", style)
+	}
+	b.card.Header.ImageUrl = url
+	b.card.Header.ImageStyle = style
+	return b
+}
+
+// Section starts a new section. The header can be empty.
+func (b *CardBuilder) Section(header string) *CardBuilder {
+	b.card.Sections = append(b.card.Sections, &Section{Header: header})
+	return b
+}
+
+// CardAction adds an item opening url to the menu of the card
+func (b *CardBuilder) CardAction(label, url string) *CardBuilder {
+	if len(label) == 0 || len(url) == 0 {
+		return b.errorf("card.cardActions[%d]: both label and url are required", len(b.card.CardActions))
+	}
+	b.card.CardActions = append(b.card.CardActions, &CardAction{
+		ActionLabel: label,
+		OnClick:     openLink(url),
+	})
+	return b
+}
+
+func (b *CardBuilder) KeyValue(topLabel, content string) *CardBuilder {
+	return b.addWidget(&WidgetMarkup{
+		KeyValue: &KeyValue{TopLabel: topLabel, Content: content},
+	})
+}
+
+// Text adds a paragraph, which may use the HTML subset supported by Chat
+func (b *CardBuilder) Text(text string) *CardBuilder {
+	return b.addWidget(&WidgetMarkup{
+		TextParagraph: &TextParagraph{Text: text},
+	})
+}
+
+func (b *CardBuilder) Image(url string) *CardBuilder {
+	if len(url) == 0 {
+		return b.errorf("%s: image without url", b.position())
+	}
+	return b.addWidget(&WidgetMarkup{
+		Image: &Image{ImageUrl: url},
+	})
+}
+
+// Widget adds a widget built by hand. It is validated by Build like the
+// widgets added by the other methods.
+func (b *CardBuilder) Widget(w *WidgetMarkup) *CardBuilder {
+	return b.addWidget(w)
+}
+
+// BottomLabel sets the bottom label of the last widget, which must be a key
+// value
+func (b *CardBuilder) BottomLabel(label string) *CardBuilder {
+	if kv := b.lastKeyValue("bottom label"); kv != nil {
+		kv.BottomLabel = label
+	}
+	return b
+}
+
+// Multiline lets the content of the last key value wrap
+func (b *CardBuilder) Multiline() *CardBuilder {
+	if kv := b.lastKeyValue("multiline"); kv != nil {
+		kv.ContentMultiline = true
+	}
+	return b
+}
+
+// Icon sets one of the built-in icons of Chat, e.g. "STAR", on the last key
+// value
+func (b *CardBuilder) Icon(icon string) *CardBuilder {
+	if kv := b.lastKeyValue("icon"); kv != nil {
+		if len(kv.IconUrl) > 0 {
+			return b.errorf("%s: key value has both icon and icon url", b.position())
+		}
+		kv.Icon = icon
+	}
+	return b
+}
+
+// IconURL sets the icon of the last key value to an image
+func (b *CardBuilder) IconURL(url string) *CardBuilder {
+	if kv := b.lastKeyValue("icon url"); kv != nil {
+		if len(kv.Icon) > 0 {
+			return b.errorf("%s: key value has both icon and icon url", b.position())
+		}
+		kv.IconUrl = url
+	}
+	return b
+}
+
+// Link opens url when the last widget, a key value or an image, is clicked
+func (b *CardBuilder) Link(url string) *CardBuilder {
+	w := b.lastWidget()
+	switch {
+	case len(url) == 0:
+		b.errorf("%s: link without url", b.position())
+	case w != nil && w.KeyValue != nil:
+		w.KeyValue.OnClick = openLink(url)
+	case w != nil && w.Image != nil:
+		w.Image.OnClick = openLink(url)
+	default:
+		b.errorf("%s: link must follow a key value or an image", b.position())
+	}
+	return b
+}
+
+// Button adds a text button opening url. Buttons following each other share
+// a single widget.
+func (b *CardBuilder) Button(text, url string) *CardBuilder {
+	if len(text) == 0 || len(url) == 0 {
+		return b.errorf("%s: button needs both text and url", b.position())
+	}
+	return b.addButton(&Button{
+		TextButton: &TextButton{Text: text, OnClick: openLink(url)},
+	})
+}
+
+// IconButton adds a button showing one of the built-in icons of Chat. Name
+// is read by screen readers.
+func (b *CardBuilder) IconButton(icon, name, url string) *CardBuilder {
+	if len(icon) == 0 || len(url) == 0 {
+		return b.errorf("%s: icon button needs both icon and url", b.position())
+	}
+	return b.addButton(&Button{
+		ImageButton: &ImageButton{Icon: icon, Name: name, OnClick: openLink(url)},
+	})
+}
+
+// ImageButton adds a button showing the image at iconUrl
+func (b *CardBuilder) ImageButton(iconUrl, name, url string) *CardBuilder {
+	if len(iconUrl) == 0 || len(url) == 0 {
+		return b.errorf("%s: image button needs both icon url and url", b.position())
+	}
+	return b.addButton(&Button{
+		ImageButton: &ImageButton{IconUrl: iconUrl, Name: name, OnClick: openLink(url)},
+	})
+}
+
+// Build returns the card, or a CardError listing every problem found. The
+// problems found by validating the card are named by their path, e.g.
+// card.sections[0].widgets[1].keyValue.
+func (b *CardBuilder) Build() (*Card, error) {
+	errs := append(CardError(nil), b.errs...)
+	v := &validator{}
+	v.card("card", b.card, DefaultLimits)
+	for _, fe := range v.errs {
+		errs = append(errs, fe.Error())
+	}
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	return b.card, nil
+}
+
+func (b *CardBuilder) section() *Section {
+	if len(b.card.Sections) == 0 {
+		b.Section("")
+	}
+	return b.card.Sections[len(b.card.Sections)-1]
+}
+
+func (b *CardBuilder) lastWidget() *WidgetMarkup {
+	if len(b.card.Sections) == 0 {
+		return nil
+	}
+	widgets := b.section().Widgets
+	if len(widgets) == 0 {
+		return nil
+	}
+	return widgets[len(widgets)-1]
+}
+
+func (b *CardBuilder) lastKeyValue(what string) *KeyValue {
+	w := b.lastWidget()
+	if w == nil || w.KeyValue == nil {
+		b.errorf("%s: %s must follow a key value", b.position(), what)
+		return nil
+	}
+	return w.KeyValue
+}
+
+// position names the last widget in errors the way Build does, e.g.
+// card.sections[1].widgets[0]
+func (b *CardBuilder) position() string {
+	if len(b.card.Sections) == 0 {
+		return "card.sections[0]"
+	}
+	s := len(b.card.Sections)
+	w := len(b.card.Sections[s-1].Widgets)
+	if w == 0 {
+		return fmt.Sprintf("card.sections[%d]", s-1)
+	}
+	return fmt.Sprintf("card.sections[%d].widgets[%d]", s-1, w-1)
+}
+
+func (b *CardBuilder) addWidget(w *WidgetMarkup) *CardBuilder {
+	s := b.section()
+	s.Widgets = append(s.Widgets, w)
+	return b
+}
+
+func (b *CardBuilder) addButton(button *Button) *CardBuilder {
+	if w := b.lastWidget(); w != nil && len(w.Buttons) > 0 {
+		w.Buttons = append(w.Buttons, button)
+		return b
+	}
+	return b.addWidget(&WidgetMarkup{Buttons: []*Button{button}})
+}
+
+func openLink(url string) *OnClick {
+	return &OnClick{OpenLink: &OpenLink{Url: url}}
+}
@@ -0,0 +1,80 @@
+package hangouts
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCardBuilder(t *testing.T) {
+	tests := []struct {
+		name  string
+		build func() (*Card, error)
+		errs  CardError
+	}{
+		{
+			"valid",
+			func() (*Card, error) {
+				return NewCard().
+					Header("Checks", "owner/repo#1").
+					Section("Summary").
+					KeyValue("Status", "2/3 passed").Link("https://github.com").
+					Button("View", "https://github.com").
+					Button("Checks", "https://github.com").
+					Build()
+			},
+			nil,
+		},
+		{
+			"no sections",
+			func() (*Card, error) { return NewCard().Header("Title", "").Build() },
+			CardError{"card.sections: card has no sections"},
+		},
+		{
+			"builder errors",
+			func() (*Card, error) {
+				return NewCard().
+					HeaderImage("https://example.com/a.png", ImageStyleAvatar).
+					Section("").Link("https://github.com").
+					KeyValue("a", "b").Icon("STAR").IconURL("https://example.com/a.png").
+					Build()
+			},
+			CardError{
+				"card.header.imageUrl: Header must be called first",
+				"card.sections[0]: link must follow a key value or an image",
+				"card.sections[0].widgets[0]: key value has both icon and icon url",
+			},
+		},
+		{
+			"validation errors",
+			func() (*Card, error) {
+				return NewCard().
+					Header("", "").
+					Section("").
+					Widget(&WidgetMarkup{}).
+					KeyValue("a", "b").Link("ftp://example.com").
+					Section("").
+					Build()
+			},
+			CardError{
+				"card.header.title: empty title",
+				"card.sections[0].widgets[0]: exactly one of buttons, image, keyValue and textParagraph must be set, got 0",
+				`card.sections[0].widgets[1].keyValue.onClick.openLink.url: scheme "ftp" not allowed, links must use http or https`,
+				"card.sections[1].widgets: section has no widgets",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			card, err := tt.build()
+			if tt.errs == nil {
+				if err != nil || card == nil {
+					t.Errorf("Build() = %v, %v, want a card", card, err)
+				}
+				return
+			}
+			if !reflect.DeepEqual(err, tt.errs) {
+				t.Errorf("Build() error = %q, want %q", err, tt.errs)
+			}
+		})
+	}
+}
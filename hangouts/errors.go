@@ -0,0 +1,147 @@
+package hangouts
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Kinds of failures reported by the Chat API. An *APIError wraps one of
+// these, so that they can be told apart with errors.Is. Other failures, e.g.
+// a 409 conflict, wrap none of them.
+var (
+	// ErrUnauthorized is returned when the webhook or the credentials are
+	// invalid or do not have access to the space
+	ErrUnauthorized = errors.New("unauthorized")
+	// ErrQuotaExceeded is returned when too many requests are made
+	ErrQuotaExceeded = errors.New("quota exceeded")
+	// ErrInvalidMessage is returned when the message is rejected, e.g. when
+	// it has an invalid card
+	ErrInvalidMessage = errors.New("invalid message")
+	// ErrNotFound is returned when the space, the webhook or the message does
+	// not exist (anymore)
+	ErrNotFound = errors.New("not found")
+	// ErrServer is returned when the Chat API fails to handle the request
+	ErrServer = errors.New("server error")
+)
+
+// Query parameters holding credentials, which are redacted from urls
+var credentialParams = []string{"key", "token", "access_token"}
+
+// APIError is returned when the Chat API responds with a non 2xx status code.
+// The fields other than StatusCode, Method and URL are taken from the error
+// envelope of the response when it has one.
+type APIError struct {
+	// StatusCode is the HTTP status code of the response
+	StatusCode int `json:"-"`
+	// Method and URL of the request. Credentials are redacted from the URL.
+	Method string `json:"-"`
+	URL    string `json:"-"`
+
+	// Code is the HTTP status code reported in the error envelope
+	Code int `json:"code"`
+	// Status is the canonical error code, e.g. INVALID_ARGUMENT
+	Status string `json:"status"`
+	// Message describes the error
+	Message string `json:"message"`
+	// Details has additional error information, e.g. the fields of the
+	// message that were rejected
+	Details []map[string]interface{} `json:"details"`
+
+	// Body is the raw response body
+	Body string `json:"-"`
+	// RetryAfter is the wait asked for by the Retry-After header
+	RetryAfter time.Duration `json:"-"`
+}
+
+func newAPIError(req *http.Request, resp *http.Response, body []byte) *APIError {
+	e := &APIError{
+		StatusCode: resp.StatusCode,
+		Method:     req.Method,
+		URL:        redactURL(req.URL.String()),
+		Body:       string(body),
+		RetryAfter: parseRetryAfter(resp.Header),
+	}
+	envelope := struct {
+		Error *APIError `json:"error"`
+	}{Error: e}
+	if err := json.Unmarshal(body, &envelope); err != nil || len(e.Message) == 0 {
+		e.Message = strings.TrimSpace(string(body))
+	}
+	return e
+}
+
+func (e *APIError) Error() string {
+	status := e.Status
+	if len(status) == 0 {
+		status = http.StatusText(e.StatusCode)
+	}
+	path := e.URL
+	if u, err := url.Parse(e.URL); err == nil {
+		path = u.Path
+	}
+	return fmt.Sprintf("chat api error: %s %s: %d %s: %s", e.Method, path, e.StatusCode, status, e.Message)
+}
+
+func (e *APIError) Unwrap() error {
+	switch {
+	case e.StatusCode == http.StatusUnauthorized || e.StatusCode == http.StatusForbidden:
+		return ErrUnauthorized
+	case e.StatusCode == http.StatusTooManyRequests:
+		return ErrQuotaExceeded
+	case e.StatusCode >= 500:
+		return ErrServer
+	case e.StatusCode == http.StatusBadRequest || e.Status == "INVALID_ARGUMENT":
+		return ErrInvalidMessage
+	case e.StatusCode == http.StatusNotFound:
+		return ErrNotFound
+	default:
+		return nil
+	}
+}
+
+// redactURL hides the credentials in the query of the url
+func redactURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "<invalid url>"
+	}
+	q := u.Query()
+	for _, p := range credentialParams {
+		if _, ok := q[p]; ok {
+			q.Set(p, "REDACTED")
+		}
+	}
+	u.RawQuery = q.Encode()
+	return u.String()
+}
+
+// redactError hides the credentials in the url of transport errors, which
+// would otherwise end up in the logs of the action
+func redactError(err error) error {
+	if ue, ok := err.(*url.Error); ok {
+		return &url.Error{Op: ue.Op, URL: redactURL(ue.URL), Err: ue.Err}
+	}
+	return err
+}
+
+// retryable reports whether the request may succeed when it is sent again.
+// Only failures to get a response at all and 429 or 5xx responses are
+// retried. Anything going wrong after a 2xx response, e.g. a body that cannot
+// be decoded, is not, as the request has been handled already.
+func retryable(err error) bool {
+	var ue *url.Error
+	if errors.As(err, &ue) {
+		// Failed to get a response at all, e.g. the connection was reset
+		return true
+	}
+	var ae *APIError
+	if !errors.As(err, &ae) {
+		return false
+	}
+	return errors.Is(err, ErrQuotaExceeded) || errors.Is(err, ErrServer)
+}
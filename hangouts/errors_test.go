@@ -0,0 +1,94 @@
+package hangouts
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestAPIErrorUnwrap(t *testing.T) {
+	tests := []struct {
+		status     int
+		statusName string
+		want       error
+	}{
+		{http.StatusBadRequest, "", ErrInvalidMessage},
+		{http.StatusBadRequest, "FAILED_PRECONDITION", ErrInvalidMessage},
+		{http.StatusUnauthorized, "", ErrUnauthorized},
+		{http.StatusForbidden, "PERMISSION_DENIED", ErrUnauthorized},
+		{http.StatusNotFound, "NOT_FOUND", ErrNotFound},
+		{http.StatusRequestTimeout, "", nil},
+		{http.StatusConflict, "ALREADY_EXISTS", nil},
+		{http.StatusUnprocessableEntity, "INVALID_ARGUMENT", ErrInvalidMessage},
+		{http.StatusTooManyRequests, "RESOURCE_EXHAUSTED", ErrQuotaExceeded},
+		{http.StatusServiceUnavailable, "UNAVAILABLE", ErrServer},
+	}
+	sentinels := []error{ErrUnauthorized, ErrQuotaExceeded, ErrInvalidMessage, ErrNotFound, ErrServer}
+	for _, tt := range tests {
+		err := &APIError{StatusCode: tt.status, Status: tt.statusName}
+		for _, s := range sentinels {
+			if got := errors.Is(err, s); got != (s == tt.want) {
+				t.Errorf("errors.Is(%d %s, %v) = %v, want %v", tt.status, tt.statusName, s, got, !got)
+			}
+		}
+	}
+}
+
+func TestRedactURL(t *testing.T) {
+	tests := map[string]string{
+		"https://chat.googleapis.com/v1/spaces/x/messages?key=k1&token=t1&threadKey=a":        "https://chat.googleapis.com/v1/spaces/x/messages?key=REDACTED&threadKey=a&token=REDACTED",
+		"https://chat.googleapis.com/v1/spaces/x/messages/1?access_token=t2&updateMask=cards": "https://chat.googleapis.com/v1/spaces/x/messages/1?access_token=REDACTED&updateMask=cards",
+		"https://chat.googleapis.com/v1/spaces/x/messages":                                    "https://chat.googleapis.com/v1/spaces/x/messages",
+		"https://chat.googleapis.com/v1/%zz?key=k1":                                           "<invalid url>",
+	}
+	for raw, want := range tests {
+		if got := redactURL(raw); got != want {
+			t.Errorf("redactURL(%q) = %q, want %q", raw, got, want)
+		}
+	}
+}
+
+// secrets are the credentials in the query of the requests of the tests below
+var secrets = []string{"s3cr3t-key", "s3cr3t-token", "s3cr3t-access-token"}
+
+const secretQuery = "?key=s3cr3t-key&token=s3cr3t-token&access_token=s3cr3t-access-token"
+
+func assertRedacted(t *testing.T, what string, s string) {
+	t.Helper()
+	for _, secret := range secrets {
+		if strings.Contains(s, secret) {
+			t.Errorf("%s %q contains %s", what, s, secret)
+		}
+	}
+}
+
+func TestAPIErrorRedacted(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error": {"code": 400, "status": "INVALID_ARGUMENT", "message": "Invalid JSON payload"}}`))
+	}))
+	defer srv.Close()
+	err := doJSON(context.Background(), srv.Client(), RetryPolicy{}, http.MethodPost, srv.URL+"/v1/spaces/x/messages"+secretQuery, &Message{Text: "hi"}, nil)
+	var ae *APIError
+	if !errors.As(err, &ae) {
+		t.Fatalf("err = %v, want an APIError", err)
+	}
+	assertRedacted(t, "error", err.Error())
+	assertRedacted(t, "error url", ae.URL)
+}
+
+func TestTransportErrorRedacted(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	srv.Close()
+	policy := RetryPolicy{MaxRetries: 1, InitialBackoff: 1}
+	for _, method := range []string{http.MethodPost, http.MethodPatch} {
+		err := doJSON(context.Background(), http.DefaultClient, policy, method, srv.URL+"/v1/spaces/x/messages"+secretQuery, &Message{Text: "hi"}, nil)
+		if err == nil {
+			t.Fatalf("%s to a closed server did not fail", method)
+		}
+		assertRedacted(t, method+" error", err.Error())
+	}
+}
@@ -1,49 +1,117 @@
 package hangouts
 
 import (
-	"bytes"
-	"encoding/json"
+	"context"
 	"fmt"
-	"io/ioutil"
 	"net/http"
+	"net/url"
+	"strings"
 )
 
+// Client posts messages to a space through an incoming webhook
 type Client struct {
 	*http.Client
-	URL string
+	URL   string
+	Retry RetryPolicy
+	Check MessageCheck
+
+	messages messageNames
 }
 
-func NewWebhookClient(url string) *Client {
-	return &Client{
-		Client: &http.Client{},
+func NewWebhookClient(url string, opts ...ClientOption) *Client {
+	c := &Client{
+		Client: &http.Client{Timeout: DefaultTimeout},
 		URL:    url,
+		Retry:  DefaultRetryPolicy,
+	}
+	cfg := &clientConfig{client: c.Client, retry: &c.Retry, check: &c.Check}
+	for _, opt := range opts {
+		opt(cfg)
 	}
+	return c
 }
 
 func (h *Client) Send(threadKey string, msg *Message) (*Message, error) {
-	url := h.URL
-	if len(threadKey) > 0 {
-		url = fmt.Sprintf("%s&threadKey=%s", url, threadKey)
+	return h.SendContext(context.Background(), threadKey, msg)
+}
+
+// SendContext is like Send, but gives up on the request and any retry of it
+// when ctx is done.
+func (h *Client) SendContext(ctx context.Context, threadKey string, msg *Message) (*Message, error) {
+	if len(threadKey) == 0 {
+		return h.Post(ctx, msg)
 	}
-	data, err := json.Marshal(msg)
+	return h.Post(ctx, msg, WithThreadKey(threadKey), WithMessageReplyOption(ReplyMessageFallbackToNewThread))
+}
+
+// Post posts msg to the space of the webhook. The options set the thread, the
+// request ID and such.
+func (h *Client) Post(ctx context.Context, msg *Message, opts ...SendOption) (*Message, error) {
+	u, err := h.webhookURL()
 	if err != nil {
 		return nil, err
 	}
-	resp, err := h.Client.Post(url, "application/json", bytes.NewBuffer(data))
+	q := u.Query()
+	for _, opt := range opts {
+		opt(q)
+	}
+	u.RawQuery = q.Encode()
+	return h.do(ctx, http.MethodPost, u.String(), msg)
+}
+
+// Update replaces the fields listed in updateMask (e.g. "cards") of the
+// message with the given resource name, using the credentials of the webhook.
+func (h *Client) Update(name string, msg *Message, updateMask ...string) (*Message, error) {
+	return h.UpdateContext(context.Background(), name, msg, updateMask...)
+}
+
+// UpdateContext is like Update, but gives up on the request and any retry of
+// it when ctx is done.
+func (h *Client) UpdateContext(ctx context.Context, name string, msg *Message, updateMask ...string) (*Message, error) {
+	if len(updateMask) == 0 {
+		return nil, fmt.Errorf("update message error: empty update mask")
+	}
+	u, err := h.webhookURL()
 	if err != nil {
 		return nil, err
 	}
-	if resp.StatusCode/100 != 2 {
-		body, _ := ioutil.ReadAll(resp.Body)
-		return nil, fmt.Errorf("post message error: %s", body)
+	u.Path = "/v1/" + name
+	q := u.Query()
+	q.Set("updateMask", strings.Join(updateMask, ","))
+	u.RawQuery = q.Encode()
+	return h.do(ctx, http.MethodPatch, u.String(), msg)
+}
+
+// Upsert posts msg the first time it is called with the given id and updates
+// that message on later calls, so that a single message reflects the latest
+// state of whatever the id stands for. When the update is rejected, e.g.
+// because the message was deleted, a new message is posted instead.
+func (h *Client) Upsert(id, threadKey string, msg *Message, updateMask ...string) (*Message, error) {
+	return h.UpsertContext(context.Background(), id, threadKey, msg, updateMask...)
+}
+
+// UpsertContext is like Upsert, but gives up on the request and any retry of
+// it when ctx is done.
+func (h *Client) UpsertContext(ctx context.Context, id, threadKey string, msg *Message, updateMask ...string) (*Message, error) {
+	return h.messages.upsert(ctx, h, id, threadKey, msg, updateMask)
+}
+
+func (h *Client) webhookURL() (*url.URL, error) {
+	u, err := url.Parse(h.URL)
+	if err != nil {
+		// The parse error contains the url, which holds the webhook credentials
+		return nil, fmt.Errorf("invalid webhook url")
 	}
-	body, err := ioutil.ReadAll(resp.Body)
+	return u, nil
+}
+
+func (h *Client) do(ctx context.Context, method, url string, msg *Message) (*Message, error) {
+	msg, err := h.Check.prepare(msg)
 	if err != nil {
 		return nil, err
 	}
 	rMsg := &Message{}
-	err = json.Unmarshal(body, rMsg)
-	if err != nil {
+	if err := doJSON(ctx, h.Client, h.Retry, method, url, msg, rMsg); err != nil {
 		return nil, err
 	}
 	return rMsg, nil
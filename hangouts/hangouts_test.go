@@ -0,0 +1,98 @@
+package hangouts
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestClientPost(t *testing.T) {
+	tests := []struct {
+		name    string
+		webhook string
+		opts    []SendOption
+		want    url.Values
+		// rawToken is the token as sent, still escaped
+		rawToken string
+	}{
+		{
+			name:    "no query",
+			webhook: "/v1/spaces/x/messages",
+			want:    url.Values{},
+		},
+		{
+			name:     "escaped token",
+			webhook:  "/v1/spaces/x/messages?key=k&token=abc%3D",
+			want:     url.Values{"key": {"k"}, "token": {"abc="}},
+			rawToken: "abc%3D",
+		},
+		{
+			name:    "thread key with reserved characters",
+			webhook: "/v1/spaces/x/messages?key=k&token=t",
+			opts:    []SendOption{WithThreadKey("o/r-1&a=b#c"), WithMessageReplyOption(ReplyMessageFallbackToNewThread)},
+			want: url.Values{
+				"key":                {"k"},
+				"token":              {"t"},
+				"threadKey":          {"o/r-1&a=b#c"},
+				"messageReplyOption": {"REPLY_MESSAGE_FALLBACK_TO_NEW_THREAD"},
+			},
+		},
+		{
+			name:    "request id",
+			webhook: "/v1/spaces/x/messages?key=k&token=t",
+			opts:    []SendOption{WithRequestID("id-1"), WithMessageReplyOption(ReplyMessageOrFail)},
+			want: url.Values{
+				"key":                {"k"},
+				"token":              {"t"},
+				"requestId":          {"id-1"},
+				"messageReplyOption": {"REPLY_MESSAGE_OR_FAIL"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got *http.Request
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got = r
+				w.Write([]byte(`{"name": "spaces/x/messages/1"}`))
+			}))
+			defer srv.Close()
+			client := NewWebhookClient(srv.URL+tt.webhook, WithRetryPolicy(RetryPolicy{}))
+			if _, err := client.Post(context.Background(), &Message{Text: "hi"}, tt.opts...); err != nil {
+				t.Fatal(err)
+			}
+			if got.URL.Path != "/v1/spaces/x/messages" {
+				t.Errorf("path = %q", got.URL.Path)
+			}
+			if q := got.URL.Query(); !reflect.DeepEqual(q, tt.want) {
+				t.Errorf("query = %v, want %v", q, tt.want)
+			}
+			if len(tt.rawToken) > 0 && !strings.Contains(got.URL.RawQuery, "token="+tt.rawToken) {
+				t.Errorf("raw query = %q, want the token %s", got.URL.RawQuery, tt.rawToken)
+			}
+		})
+	}
+}
+
+func TestClientSendContext(t *testing.T) {
+	var got url.Values
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.URL.Query()
+		w.Write([]byte(`{}`))
+	}))
+	defer srv.Close()
+	client := NewWebhookClient(srv.URL + "/v1/spaces/x/messages?key=k&token=t")
+	if _, err := client.SendContext(context.Background(), "o/r-1", &Message{Text: "hi"}); err != nil {
+		t.Fatal(err)
+	}
+	if got.Get("threadKey") != "o/r-1" || got.Get("messageReplyOption") != string(ReplyMessageFallbackToNewThread) {
+		t.Errorf("query = %v, want the thread key and the fallback to a new thread", got)
+	}
+	if len(got.Get("requestId")) == 0 {
+		t.Errorf("query = %v, want a request id as the post may be retried", got)
+	}
+}
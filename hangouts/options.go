@@ -0,0 +1,89 @@
+package hangouts
+
+import (
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// DefaultTimeout bounds each request to the Chat API, including reading the
+// response, unless another timeout is set with WithTimeout.
+const DefaultTimeout = 30 * time.Second
+
+// ClientOption configures a client created by NewWebhookClient or
+// NewAPIClient
+type ClientOption func(c *clientConfig)
+
+type clientConfig struct {
+	client *http.Client
+	retry  *RetryPolicy
+	check  *MessageCheck
+}
+
+// WithTimeout sets the time limit of each request. Zero means no limit.
+func WithTimeout(timeout time.Duration) ClientOption {
+	return func(c *clientConfig) {
+		c.client.Timeout = timeout
+	}
+}
+
+// WithTransport sets the transport used to make requests, e.g. to go through
+// a proxy or to stub the Chat API in tests.
+func WithTransport(rt http.RoundTripper) ClientOption {
+	return func(c *clientConfig) {
+		c.client.Transport = rt
+	}
+}
+
+// WithRetryPolicy sets how failed requests are retried
+func WithRetryPolicy(policy RetryPolicy) ClientOption {
+	return func(c *clientConfig) {
+		*c.retry = policy
+	}
+}
+
+// WithMessageCheck sets what is done with messages before they are sent, see
+// MessageCheck
+func WithMessageCheck(check MessageCheck) ClientOption {
+	return func(c *clientConfig) {
+		*c.check = check
+	}
+}
+
+// MessageReplyOption tells how a message with a thread key is threaded
+type MessageReplyOption string
+
+const (
+	// ReplyMessageFallbackToNewThread replies to the thread of the thread key
+	// or starts a new thread when there is no such thread
+	ReplyMessageFallbackToNewThread MessageReplyOption = "REPLY_MESSAGE_FALLBACK_TO_NEW_THREAD"
+	// ReplyMessageOrFail replies to the thread of the thread key and fails
+	// when there is no such thread
+	ReplyMessageOrFail MessageReplyOption = "REPLY_MESSAGE_OR_FAIL"
+)
+
+// SendOption sets a query parameter of a request to post a message
+type SendOption func(q url.Values)
+
+// WithThreadKey posts the message to the thread identified by key. Keys are
+// chosen by the caller and may contain any character.
+func WithThreadKey(key string) SendOption {
+	return func(q url.Values) {
+		q.Set("threadKey", key)
+	}
+}
+
+// WithMessageReplyOption sets how the thread key is used
+func WithMessageReplyOption(opt MessageReplyOption) SendOption {
+	return func(q url.Values) {
+		q.Set("messageReplyOption", string(opt))
+	}
+}
+
+// WithRequestID makes posting the message idempotent. Posting again with the
+// same request ID returns the message posted first instead of a new one.
+func WithRequestID(id string) SendOption {
+	return func(q url.Values) {
+		q.Set("requestId", id)
+	}
+}
@@ -0,0 +1,105 @@
+package hangouts
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	neturl "net/url"
+	"time"
+)
+
+// doJSON sends in as the JSON body of the request and decodes the JSON
+// response into out, retrying the request as long as the retry policy
+// allows. Every attempt carries the same url and body, so a retried message
+// ends up in the same thread the first attempt would have. Posts that may be
+// retried get a request ID unless they have one, so that a post handled by
+// the server despite a failed attempt is not posted twice. Either of in and
+// out may be nil.
+func doJSON(ctx context.Context, hc *http.Client, retry RetryPolicy, method, url string, in, out interface{}) error {
+	if method == http.MethodPost && retry.MaxRetries > 0 {
+		var err error
+		if url, err = withRequestID(url); err != nil {
+			return err
+		}
+	}
+	var data []byte
+	if in != nil {
+		var err error
+		data, err = json.Marshal(in)
+		if err != nil {
+			return err
+		}
+	}
+	for attempt := 0; ; attempt++ {
+		err := doJSONOnce(ctx, hc, method, url, data, out)
+		if err == nil || attempt >= retry.MaxRetries || ctx.Err() != nil || !retryable(err) {
+			return err
+		}
+		var retryAfter time.Duration
+		if ae, ok := err.(*APIError); ok {
+			retryAfter = ae.RetryAfter
+		}
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(retry.backoff(attempt, retryAfter)):
+		}
+	}
+}
+
+// withRequestID adds a generated request ID to the url unless it has one
+func withRequestID(raw string) (string, error) {
+	u, err := neturl.Parse(raw)
+	if err != nil {
+		// The parse error contains the url, which may hold credentials
+		return "", fmt.Errorf("invalid request url")
+	}
+	q := u.Query()
+	if len(q.Get("requestId")) > 0 {
+		return raw, nil
+	}
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return "", err
+	}
+	WithRequestID(hex.EncodeToString(id))(q)
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
+
+func doJSONOnce(ctx context.Context, hc *http.Client, method, url string, data []byte, out interface{}) error {
+	var body io.Reader
+	if data != nil {
+		body = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(method, url, body)
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	if data != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := hc.Do(req)
+	if err != nil {
+		return redactError(err)
+	}
+	defer resp.Body.Close()
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if resp.StatusCode/100 != 2 {
+		return newAPIError(req, resp, respBody)
+	}
+	if err != nil {
+		return err
+	}
+	if out == nil || len(respBody) == 0 {
+		return nil
+	}
+	return json.Unmarshal(respBody, out)
+}
@@ -0,0 +1,99 @@
+package hangouts
+
+import (
+	"math"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// RetryPolicy controls how failed requests to the Chat API are retried.
+// Requests are retried when the API is rate limiting (429) or failing (5xx)
+// and when no response is received at all.
+type RetryPolicy struct {
+	// MaxRetries is the number of times a request is retried, zero disables
+	// retries
+	MaxRetries int
+	// InitialBackoff is the wait before the first retry
+	InitialBackoff time.Duration
+	// MaxBackoff caps the wait between two retries
+	MaxBackoff time.Duration
+	// Multiplier grows the wait after each retry
+	Multiplier float64
+}
+
+var DefaultRetryPolicy = RetryPolicy{
+	MaxRetries:     3,
+	InitialBackoff: time.Second,
+	MaxBackoff:     30 * time.Second,
+	Multiplier:     2,
+}
+
+// Backoff computes waits growing exponentially up to a maximum, randomized so
+// that concurrent runs do not retry or poll in lockstep.
+type Backoff struct {
+	// Initial is the first wait
+	Initial time.Duration
+	// Max caps the waits, zero means no cap
+	Max time.Duration
+	// Multiplier grows the wait after each attempt
+	Multiplier float64
+	// Jitter shortens each wait by a random fraction of it, up to this one
+	Jitter float64
+}
+
+// jitter is seeded as the global source always starts from the same seed with
+// Go < 1.20, and locked as backoffs may be computed by several goroutines.
+var jitter = struct {
+	sync.Mutex
+	*rand.Rand
+}{Rand: rand.New(rand.NewSource(time.Now().UnixNano()))}
+
+// Wait returns the wait before the given attempt, counting from zero
+func (b Backoff) Wait(attempt int) time.Duration {
+	wait := float64(b.Initial) * math.Pow(math.Max(b.Multiplier, 1), float64(attempt))
+	if b.Max > 0 && wait > float64(b.Max) {
+		wait = float64(b.Max)
+	}
+	if b.Jitter > 0 {
+		jitter.Lock()
+		wait -= wait * b.Jitter * jitter.Float64()
+		jitter.Unlock()
+	}
+	return time.Duration(wait)
+}
+
+// backoff returns the wait before the given retry, counting from zero. A
+// Retry-After sent by the server takes precedence when it asks for longer.
+func (p RetryPolicy) backoff(retry int, retryAfter time.Duration) time.Duration {
+	wait := Backoff{
+		Initial:    p.InitialBackoff,
+		Max:        p.MaxBackoff,
+		Multiplier: p.Multiplier,
+		Jitter:     0.5,
+	}.Wait(retry)
+	if wait < retryAfter {
+		return retryAfter
+	}
+	return wait
+}
+
+// parseRetryAfter reads the Retry-After header, which is either a number of
+// seconds or an HTTP date.
+func parseRetryAfter(h http.Header) time.Duration {
+	v := h.Get("Retry-After")
+	if len(v) == 0 {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(v); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
@@ -0,0 +1,172 @@
+package hangouts
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	p := RetryPolicy{
+		MaxRetries:     5,
+		InitialBackoff: time.Second,
+		MaxBackoff:     5 * time.Second,
+		Multiplier:     2,
+	}
+	tests := []struct {
+		retry      int
+		retryAfter time.Duration
+		min, max   time.Duration
+	}{
+		{0, 0, 500 * time.Millisecond, time.Second},
+		{1, 0, time.Second, 2 * time.Second},
+		{2, 0, 2 * time.Second, 4 * time.Second},
+		{3, 0, 2500 * time.Millisecond, 5 * time.Second},
+		{10, 0, 2500 * time.Millisecond, 5 * time.Second},
+		{0, 10 * time.Second, 10 * time.Second, 10 * time.Second},
+		{2, time.Millisecond, 2 * time.Second, 4 * time.Second},
+	}
+	for _, tt := range tests {
+		for i := 0; i < 20; i++ {
+			got := p.backoff(tt.retry, tt.retryAfter)
+			if got < tt.min || got > tt.max {
+				t.Errorf("backoff(%d, %v) = %v, want within [%v, %v]", tt.retry, tt.retryAfter, got, tt.min, tt.max)
+				break
+			}
+		}
+	}
+}
+
+func TestBackoffWait(t *testing.T) {
+	tests := []struct {
+		backoff  Backoff
+		attempt  int
+		min, max time.Duration
+	}{
+		{Backoff{Initial: time.Second, Multiplier: 1.5}, 0, time.Second, time.Second},
+		{Backoff{Initial: time.Second, Multiplier: 1.5}, 2, 2250 * time.Millisecond, 2250 * time.Millisecond},
+		{Backoff{Initial: time.Second, Multiplier: 0.5}, 3, time.Second, time.Second},
+		{Backoff{Initial: time.Second, Max: 3 * time.Second, Multiplier: 2}, 5, 3 * time.Second, 3 * time.Second},
+		{Backoff{Initial: time.Second, Max: 3 * time.Second, Multiplier: 2, Jitter: 0.2}, 5, 2400 * time.Millisecond, 3 * time.Second},
+		{Backoff{Initial: 10 * time.Second, Multiplier: 1, Jitter: 0.9}, 0, time.Second, 10 * time.Second},
+	}
+	for _, tt := range tests {
+		for i := 0; i < 20; i++ {
+			if got := tt.backoff.Wait(tt.attempt); got < tt.min || got > tt.max {
+				t.Errorf("%+v.Wait(%d) = %v, want within [%v, %v]", tt.backoff, tt.attempt, got, tt.min, tt.max)
+				break
+			}
+		}
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	tests := []struct {
+		header   string
+		min, max time.Duration
+	}{
+		{"", 0, 0},
+		{"120", 2 * time.Minute, 2 * time.Minute},
+		{"0", 0, 0},
+		{"-5", 0, 0},
+		{"soon", 0, 0},
+		{time.Now().Add(time.Minute).UTC().Format(http.TimeFormat), 55 * time.Second, time.Minute},
+		{time.Now().Add(-time.Minute).UTC().Format(http.TimeFormat), 0, 0},
+	}
+	for _, tt := range tests {
+		h := http.Header{}
+		if len(tt.header) > 0 {
+			h.Set("Retry-After", tt.header)
+		}
+		if got := parseRetryAfter(h); got < tt.min || got > tt.max {
+			t.Errorf("parseRetryAfter(%q) = %v, want within [%v, %v]", tt.header, got, tt.min, tt.max)
+		}
+	}
+}
+
+func TestRetryable(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{&url.Error{Op: "Post", URL: "https://chat.googleapis.com", Err: errors.New("connection reset")}, true},
+		{&APIError{StatusCode: http.StatusTooManyRequests}, true},
+		{&APIError{StatusCode: http.StatusServiceUnavailable}, true},
+		{&APIError{StatusCode: http.StatusBadRequest}, false},
+		{&APIError{StatusCode: http.StatusUnauthorized}, false},
+		{&json.SyntaxError{}, false},
+		{fmt.Errorf("wrapped: %w", &APIError{StatusCode: http.StatusBadGateway}), true},
+	}
+	for _, tt := range tests {
+		if got := retryable(tt.err); got != tt.want {
+			t.Errorf("retryable(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestDoJSONRetries(t *testing.T) {
+	policy := RetryPolicy{MaxRetries: 3, InitialBackoff: time.Millisecond, Multiplier: 1}
+	tests := []struct {
+		name      string
+		method    string
+		responses []int
+		body      string
+		wantCalls int
+		wantErr   bool
+	}{
+		{"success", http.MethodPost, []int{200}, "{}", 1, false},
+		{"server error then success", http.MethodPost, []int{503, 200}, "{}", 2, false},
+		{"quota exceeded", http.MethodPatch, []int{429, 429, 429, 429}, "{}", 4, true},
+		{"invalid message", http.MethodPost, []int{400}, "{}", 1, true},
+		{"undecodable success", http.MethodPost, []int{200}, "{bad", 1, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var requestIds []string
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requestIds = append(requestIds, r.URL.Query().Get("requestId"))
+				status := tt.responses[len(requestIds)-1]
+				w.WriteHeader(status)
+				if status == 200 {
+					w.Write([]byte(tt.body))
+				}
+			}))
+			defer srv.Close()
+
+			err := doJSON(context.Background(), srv.Client(), policy, tt.method, srv.URL+"?key=k", &Message{Text: "hi"}, &Message{})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("err = %v, want error %v", err, tt.wantErr)
+			}
+			if len(requestIds) != tt.wantCalls {
+				t.Fatalf("%d calls, want %d", len(requestIds), tt.wantCalls)
+			}
+			for _, id := range requestIds {
+				if tt.method == http.MethodPost && (len(id) == 0 || id != requestIds[0]) {
+					t.Errorf("request ids %q, want the same id on every attempt", requestIds)
+				}
+				if tt.method != http.MethodPost && len(id) > 0 {
+					t.Errorf("request id %q set on %s", id, tt.method)
+				}
+			}
+		})
+	}
+}
+
+func TestDoJSONKeepsRequestID(t *testing.T) {
+	var got string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.URL.Query().Get("requestId")
+		w.Write([]byte("{}"))
+	}))
+	defer srv.Close()
+	err := doJSON(context.Background(), srv.Client(), DefaultRetryPolicy, http.MethodPost, srv.URL+"?requestId=mine", &Message{Text: "hi"}, nil)
+	if err != nil || got != "mine" {
+		t.Errorf("request id = %q, err = %v, want the caller's id", got, err)
+	}
+}
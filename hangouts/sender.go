@@ -0,0 +1,63 @@
+package hangouts
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+)
+
+// Sender posts and updates messages in a single space. It is implemented by
+// the webhook Client and by SpaceClient.
+type Sender interface {
+	Send(threadKey string, msg *Message) (*Message, error)
+	SendContext(ctx context.Context, threadKey string, msg *Message) (*Message, error)
+	Update(name string, msg *Message, updateMask ...string) (*Message, error)
+	UpdateContext(ctx context.Context, name string, msg *Message, updateMask ...string) (*Message, error)
+	Upsert(id, threadKey string, msg *Message, updateMask ...string) (*Message, error)
+	UpsertContext(ctx context.Context, id, threadKey string, msg *Message, updateMask ...string) (*Message, error)
+}
+
+// messageNames remembers the names of the messages posted by Upsert, by the
+// ids given by the caller
+type messageNames struct {
+	mu    sync.Mutex
+	names map[string]string
+}
+
+func (m *messageNames) upsert(ctx context.Context, s Sender, id, threadKey string, msg *Message, updateMask []string) (*Message, error) {
+	m.mu.Lock()
+	name, ok := m.names[id]
+	m.mu.Unlock()
+	if ok {
+		rMsg, err := s.UpdateContext(ctx, name, msg, updateMask...)
+		if !updateRejected(err) {
+			return rMsg, err
+		}
+		// The message cannot be updated, e.g. it was deleted or the
+		// credentials may only post messages, so a new one is posted instead
+		m.mu.Lock()
+		delete(m.names, id)
+		m.mu.Unlock()
+	}
+	rMsg, err := s.SendContext(ctx, threadKey, msg)
+	if err != nil {
+		return nil, err
+	}
+	if len(rMsg.Name) > 0 {
+		m.mu.Lock()
+		if m.names == nil {
+			m.names = make(map[string]string)
+		}
+		m.names[id] = rMsg.Name
+		m.mu.Unlock()
+	}
+	return rMsg, nil
+}
+
+// updateRejected reports whether the update was rejected with a 4xx other
+// than 429, which sending it again would not change
+func updateRejected(err error) bool {
+	var ae *APIError
+	return errors.As(err, &ae) && ae.StatusCode/100 == 4 && ae.StatusCode != http.StatusTooManyRequests
+}
@@ -0,0 +1,67 @@
+package hangouts
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// roundTripper answers the requests itself, instead of a server
+type roundTripper func(req *http.Request) *http.Response
+
+func (rt roundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	return rt(req), nil
+}
+
+func response(status int, body string) *http.Response {
+	return &http.Response{
+		StatusCode: status,
+		Header:     http.Header{},
+		Body:       ioutil.NopCloser(strings.NewReader(body)),
+	}
+}
+
+func TestUpsert(t *testing.T) {
+	tests := []struct {
+		name string
+		// update is the status code of the responses to updates
+		update int
+		want   []string
+		err    bool
+	}{
+		{"updated", http.StatusOK, []string{"POST", "PATCH messages/1", "PATCH messages/1"}, false},
+		{"message deleted", http.StatusNotFound, []string{"POST", "PATCH messages/1", "POST", "PATCH messages/2", "POST"}, false},
+		{"update forbidden", http.StatusForbidden, []string{"POST", "PATCH messages/1", "POST", "PATCH messages/2", "POST"}, false},
+		{"server error", http.StatusInternalServerError, []string{"POST", "PATCH messages/1"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var requests []string
+			posted := 0
+			client := NewWebhookClient("https://chat.googleapis.com/v1/spaces/x/messages?key=k&token=t",
+				WithRetryPolicy(RetryPolicy{}),
+				WithTransport(roundTripper(func(req *http.Request) *http.Response {
+					if req.Method == http.MethodPost {
+						requests = append(requests, "POST")
+						posted++
+						return response(http.StatusOK, fmt.Sprintf(`{"name": "spaces/x/messages/%d"}`, posted))
+					}
+					requests = append(requests, "PATCH "+strings.TrimPrefix(req.URL.Path, "/v1/spaces/x/"))
+					return response(tt.update, `{}`)
+				})))
+			var err error
+			for i := 0; i < 3 && err == nil; i++ {
+				_, err = client.UpsertContext(context.Background(), "checks", "pr-1", &Message{Text: "hi"}, "text")
+			}
+			if (err != nil) != tt.err {
+				t.Errorf("err = %v, want error %v", err, tt.err)
+			}
+			if strings.Join(requests, ", ") != strings.Join(tt.want, ", ") {
+				t.Errorf("requests = %q, want %q", requests, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,156 @@
+package hangouts
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Room left for the note added to a card whose widgets were dropped
+const truncateNoteBytes = 100
+
+// MessageCheck tells what a client does with a message before sending it
+type MessageCheck int
+
+const (
+	// CheckNone sends messages as they are
+	CheckNone MessageCheck = iota
+	// CheckValidate fails with a ValidationError instead of sending a
+	// message that breaks DefaultLimits or has invalid cards
+	CheckValidate
+	// CheckTruncate truncates messages exceeding DefaultLimits and then
+	// validates them
+	CheckTruncate
+)
+
+func (c MessageCheck) prepare(msg *Message) (*Message, error) {
+	switch c {
+	case CheckValidate:
+		return msg, msg.Validate()
+	case CheckTruncate:
+		if msg.Validate() == nil {
+			return msg, nil
+		}
+		msg = msg.Truncate(DefaultLimits)
+		return msg, msg.Validate()
+	default:
+		return msg, nil
+	}
+}
+
+// Truncate returns a copy of the message cut down to the limits. The text is
+// shortened, extra cards and buttons are dropped, and so are the last
+// widgets of cards having too many of them, or of the largest cards while
+// the message is too large. A card that lost widgets ends with a note telling
+// how many. The oneof rules are not fixed and nil cards, sections, widgets
+// and buttons are left as they are, so the copy may still be invalid.
+func (m *Message) Truncate(l Limits) *Message {
+	t := &Message{}
+	b, _ := json.Marshal(m)
+	_ = json.Unmarshal(b, t)
+
+	if r := []rune(t.Text); len(r) > l.MaxTextLength {
+		if l.MaxTextLength > 0 {
+			t.Text = string(r[:l.MaxTextLength-1]) + "…"
+		} else {
+			t.Text = ""
+		}
+	}
+	if len(t.Cards) > l.MaxCards {
+		t.Cards = t.Cards[:l.MaxCards]
+	}
+	omitted := make([]int, len(t.Cards))
+	for i, c := range t.Cards {
+		if c == nil {
+			continue
+		}
+		for _, s := range c.Sections {
+			if s == nil {
+				continue
+			}
+			for _, w := range s.Widgets {
+				if w != nil && len(w.Buttons) > l.MaxButtons {
+					w.Buttons = w.Buttons[:l.MaxButtons]
+				}
+			}
+		}
+		if n := c.WidgetCount(); n > l.MaxWidgets {
+			// One widget is left for the note
+			omitted[i] += dropWidgets(c, n-l.MaxWidgets+1)
+		}
+	}
+	for messageSize(t, omitted) > l.MaxMessageBytes {
+		largest, size := -1, 0
+		for i, c := range t.Cards {
+			if c == nil {
+				continue
+			}
+			if n := JSONSize(c); c.WidgetCount() > 1 && n > size {
+				largest, size = i, n
+			}
+		}
+		if largest < 0 {
+			break
+		}
+		omitted[largest] += dropWidgets(t.Cards[largest], 1)
+	}
+	for i, c := range t.Cards {
+		if omitted[i] > 0 && len(c.Sections) > 0 && c.Sections[len(c.Sections)-1] != nil {
+			s := c.Sections[len(c.Sections)-1]
+			s.Widgets = append(s.Widgets, &WidgetMarkup{
+				TextParagraph: &TextParagraph{Text: fmt.Sprintf("<i>%d more not shown</i>", omitted[i])},
+			})
+		}
+	}
+	return t
+}
+
+func messageSize(m *Message, omitted []int) int {
+	size := JSONSize(m)
+	for _, n := range omitted {
+		if n > 0 {
+			size += truncateNoteBytes
+		}
+	}
+	return size
+}
+
+// WidgetCount returns the number of widgets of the card over all of its
+// sections, the count limited by Limits.MaxWidgets
+func (c *Card) WidgetCount() int {
+	if c == nil {
+		return 0
+	}
+	n := 0
+	for _, s := range c.Sections {
+		if s != nil {
+			n += len(s.Widgets)
+		}
+	}
+	return n
+}
+
+// JSONSize returns the size of v once encoded to JSON, the size limited by
+// Limits.MaxMessageBytes for a message
+func JSONSize(v interface{}) int {
+	b, _ := json.Marshal(v)
+	return len(b)
+}
+
+// dropWidgets removes up to n widgets from the end of the card, along with
+// the sections left empty. The first widget is always kept.
+func dropWidgets(c *Card, n int) int {
+	dropped := 0
+	for dropped < n && c.WidgetCount() > 1 {
+		s := c.Sections[len(c.Sections)-1]
+		if s == nil || len(s.Widgets) == 0 {
+			c.Sections = c.Sections[:len(c.Sections)-1]
+			continue
+		}
+		s.Widgets = s.Widgets[:len(s.Widgets)-1]
+		dropped++
+		if len(s.Widgets) == 0 {
+			c.Sections = c.Sections[:len(c.Sections)-1]
+		}
+	}
+	return dropped
+}
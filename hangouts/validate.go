@@ -0,0 +1,251 @@
+package hangouts
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// Limits of a message enforced by the Chat API. Messages exceeding them are
+// rejected with a bare 400 response.
+type Limits struct {
+	// MaxTextLength is the maximum number of characters of the text
+	MaxTextLength int
+	// MaxMessageBytes is the maximum size of the JSON of the message
+	MaxMessageBytes int
+	MaxCards        int
+	// MaxWidgets is the maximum number of widgets of a card, over all of
+	// its sections
+	MaxWidgets int
+	// MaxButtons is the maximum number of buttons of a widget
+	MaxButtons int
+}
+
+// DefaultLimits are the limits documented by the Chat API
+var DefaultLimits = Limits{
+	MaxTextLength:   4096,
+	MaxMessageBytes: 32000,
+	MaxCards:        100,
+	MaxWidgets:      100,
+	MaxButtons:      25,
+}
+
+// FieldError is a problem of a single field of a message. Path names the
+// field the way the JSON of the message does, e.g.
+// cards[0].sections[1].widgets[2].keyValue.
+type FieldError struct {
+	Path    string
+	Problem string
+}
+
+func (e *FieldError) Error() string {
+	if len(e.Path) == 0 {
+		return e.Problem
+	}
+	return fmt.Sprintf("%s: %s", e.Path, e.Problem)
+}
+
+// ValidationError lists every problem found by Validate. It matches
+// ErrInvalidMessage with errors.Is, like the errors of messages rejected by
+// the Chat API.
+type ValidationError []*FieldError
+
+func (e ValidationError) Error() string {
+	problems := make([]string, len(e))
+	for i, fe := range e {
+		problems[i] = fe.Error()
+	}
+	return fmt.Sprintf("invalid message: %s", strings.Join(problems, "; "))
+}
+
+func (e ValidationError) Is(target error) bool {
+	return target == ErrInvalidMessage
+}
+
+// Validate checks the message against DefaultLimits and the oneof fields of
+// its cards. It returns a ValidationError, or nil when the message is valid.
+func (m *Message) Validate() error {
+	return m.ValidateLimits(DefaultLimits)
+}
+
+// ValidateLimits is like Validate with other limits
+func (m *Message) ValidateLimits(l Limits) error {
+	v := &validator{}
+	v.message(m, l)
+	if len(v.errs) > 0 {
+		return v.errs
+	}
+	return nil
+}
+
+type validator struct {
+	errs ValidationError
+}
+
+func (v *validator) errorf(path, format string, args ...interface{}) {
+	v.errs = append(v.errs, &FieldError{Path: path, Problem: fmt.Sprintf(format, args...)})
+}
+
+func (v *validator) message(m *Message, l Limits) {
+	if m == nil {
+		v.errorf("", "nil message")
+		return
+	}
+	if len(m.Text) == 0 && len(m.Cards) == 0 {
+		v.errorf("", "message has neither text nor cards")
+	}
+	if n := len([]rune(m.Text)); n > l.MaxTextLength {
+		v.errorf("text", "%d characters, at most %d allowed", n, l.MaxTextLength)
+	}
+	if len(m.Cards) > l.MaxCards {
+		v.errorf("cards", "%d cards, at most %d allowed", len(m.Cards), l.MaxCards)
+	}
+	for i, c := range m.Cards {
+		v.card(fmt.Sprintf("cards[%d]", i), c, l)
+	}
+	if b, err := json.Marshal(m); err == nil && len(b) > l.MaxMessageBytes {
+		v.errorf("", "%d bytes, at most %d allowed", len(b), l.MaxMessageBytes)
+	}
+}
+
+func (v *validator) card(path string, c *Card, l Limits) {
+	if c == nil {
+		v.errorf(path, "nil card")
+		return
+	}
+	if h := c.Header; h != nil {
+		if len(h.Title) == 0 {
+			v.errorf(path+".header.title", "empty title")
+		}
+		v.url(path+".header.imageUrl", h.ImageUrl, true)
+	}
+	for i, a := range c.CardActions {
+		v.onClick(fmt.Sprintf("%s.cardActions[%d].onClick", path, i), a.OnClick, true)
+	}
+	if len(c.Sections) == 0 {
+		v.errorf(path+".sections", "card has no sections")
+	}
+	widgets := 0
+	for i, s := range c.Sections {
+		sp := fmt.Sprintf("%s.sections[%d]", path, i)
+		if s == nil {
+			v.errorf(sp, "nil section")
+			continue
+		}
+		if len(s.Widgets) == 0 {
+			v.errorf(sp+".widgets", "section has no widgets")
+		}
+		widgets += len(s.Widgets)
+		for j, w := range s.Widgets {
+			v.widget(fmt.Sprintf("%s.widgets[%d]", sp, j), w, l)
+		}
+	}
+	if widgets > l.MaxWidgets {
+		v.errorf(path+".sections", "%d widgets, at most %d allowed", widgets, l.MaxWidgets)
+	}
+}
+
+func (v *validator) widget(path string, w *WidgetMarkup, l Limits) {
+	if w == nil {
+		v.errorf(path, "nil widget")
+		return
+	}
+	var set []string
+	if len(w.Buttons) > 0 {
+		set = append(set, "buttons")
+	}
+	if w.Image != nil {
+		set = append(set, "image")
+	}
+	if w.KeyValue != nil {
+		set = append(set, "keyValue")
+	}
+	if w.TextParagraph != nil {
+		set = append(set, "textParagraph")
+	}
+	if len(set) != 1 {
+		v.errorf(path, "exactly one of buttons, image, keyValue and textParagraph must be set, got %d", len(set))
+	}
+	if len(w.Buttons) > l.MaxButtons {
+		v.errorf(path+".buttons", "%d buttons, at most %d allowed", len(w.Buttons), l.MaxButtons)
+	}
+	for i, b := range w.Buttons {
+		v.button(fmt.Sprintf("%s.buttons[%d]", path, i), b)
+	}
+	if img := w.Image; img != nil {
+		if len(img.ImageUrl) == 0 {
+			v.errorf(path+".image.imageUrl", "empty url")
+		}
+		v.url(path+".image.imageUrl", img.ImageUrl, true)
+		v.onClick(path+".image.onClick", img.OnClick, false)
+	}
+	if kv := w.KeyValue; kv != nil {
+		if len(kv.Icon) > 0 && len(kv.IconUrl) > 0 {
+			v.errorf(path+".keyValue", "only one of icon and iconUrl can be set")
+		}
+		v.url(path+".keyValue.iconUrl", kv.IconUrl, true)
+		v.onClick(path+".keyValue.onClick", kv.OnClick, false)
+		if kv.Button != nil {
+			v.button(path+".keyValue.button", kv.Button)
+		}
+	}
+}
+
+func (v *validator) button(path string, b *Button) {
+	if b == nil {
+		v.errorf(path, "nil button")
+		return
+	}
+	if (b.TextButton == nil) == (b.ImageButton == nil) {
+		v.errorf(path, "exactly one of textButton and imageButton must be set")
+	}
+	if tb := b.TextButton; tb != nil {
+		if len(tb.Text) == 0 {
+			v.errorf(path+".textButton.text", "empty text")
+		}
+		v.onClick(path+".textButton.onClick", tb.OnClick, true)
+	}
+	if ib := b.ImageButton; ib != nil {
+		if (len(ib.Icon) == 0) == (len(ib.IconUrl) == 0) {
+			v.errorf(path+".imageButton", "exactly one of icon and iconUrl must be set")
+		}
+		v.url(path+".imageButton.iconUrl", ib.IconUrl, true)
+		v.onClick(path+".imageButton.onClick", ib.OnClick, true)
+	}
+}
+
+func (v *validator) onClick(path string, c *OnClick, required bool) {
+	if c == nil {
+		if required {
+			v.errorf(path, "missing")
+		}
+		return
+	}
+	if (c.Action == nil) == (c.OpenLink == nil) {
+		v.errorf(path, "exactly one of action and openLink must be set")
+	}
+	if c.OpenLink != nil {
+		if len(c.OpenLink.Url) == 0 {
+			v.errorf(path+".openLink.url", "empty url")
+		}
+		v.url(path+".openLink.url", c.OpenLink.Url, false)
+	}
+}
+
+// url checks the scheme of a url. Images are only loaded over https, while
+// links may use http too.
+func (v *validator) url(path, s string, image bool) {
+	if len(s) == 0 {
+		return
+	}
+	u, err := url.Parse(s)
+	switch {
+	case err != nil || len(u.Host) == 0:
+		v.errorf(path, "not an absolute url")
+	case image && u.Scheme != "https":
+		v.errorf(path, "scheme %q not allowed, images must use https", u.Scheme)
+	case !image && u.Scheme != "https" && u.Scheme != "http":
+		v.errorf(path, "scheme %q not allowed, links must use http or https", u.Scheme)
+	}
+}
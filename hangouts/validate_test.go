@@ -0,0 +1,222 @@
+package hangouts
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func keyValue(content string) *WidgetMarkup {
+	return &WidgetMarkup{KeyValue: &KeyValue{Content: content}}
+}
+
+func cardWith(widgets ...*WidgetMarkup) *Card {
+	return &Card{
+		Header:   &CardHeader{Title: "Title"},
+		Sections: []*Section{{Widgets: widgets}},
+	}
+}
+
+func TestMessageValidate(t *testing.T) {
+	tests := []struct {
+		name  string
+		msg   *Message
+		paths []string
+	}{
+		{"valid text", &Message{Text: "hi"}, nil},
+		{"valid card", &Message{Cards: []*Card{cardWith(keyValue("a"))}}, nil},
+		{"nil message", nil, []string{""}},
+		{"empty", &Message{}, []string{""}},
+		{"text too long", &Message{Text: strings.Repeat("a", 4097)}, []string{"text"}},
+		{"nil card", &Message{Cards: []*Card{nil}}, []string{"cards[0]"}},
+		{"nil section", &Message{Cards: []*Card{{Sections: []*Section{nil}}}}, []string{"cards[0].sections[0]"}},
+		{"nil widget", &Message{Cards: []*Card{cardWith(nil)}}, []string{"cards[0].sections[0].widgets[0]"}},
+		{"empty title", &Message{Cards: []*Card{{Header: &CardHeader{}, Sections: []*Section{{Widgets: []*WidgetMarkup{keyValue("a")}}}}}}, []string{"cards[0].header.title"}},
+		{"no sections", &Message{Cards: []*Card{{}}}, []string{"cards[0].sections"}},
+		{"empty section", &Message{Cards: []*Card{cardWith()}}, []string{"cards[0].sections[0].widgets"}},
+		{
+			"two contents",
+			&Message{Cards: []*Card{cardWith(&WidgetMarkup{KeyValue: &KeyValue{}, TextParagraph: &TextParagraph{}})}},
+			[]string{"cards[0].sections[0].widgets[0]"},
+		},
+		{
+			"icon and icon url",
+			&Message{Cards: []*Card{cardWith(&WidgetMarkup{KeyValue: &KeyValue{Icon: "STAR", IconUrl: "https://example.com/a.png"}})}},
+			[]string{"cards[0].sections[0].widgets[0].keyValue"},
+		},
+		{
+			"http image",
+			&Message{Cards: []*Card{cardWith(&WidgetMarkup{Image: &Image{ImageUrl: "http://example.com/a.png"}})}},
+			[]string{"cards[0].sections[0].widgets[0].image.imageUrl"},
+		},
+		{
+			"javascript link",
+			&Message{Cards: []*Card{cardWith(&WidgetMarkup{KeyValue: &KeyValue{OnClick: &OnClick{OpenLink: &OpenLink{Url: "javascript:alert(1)"}}}})}},
+			[]string{"cards[0].sections[0].widgets[0].keyValue.onClick.openLink.url"},
+		},
+		{
+			"button without onClick",
+			&Message{Cards: []*Card{cardWith(&WidgetMarkup{Buttons: []*Button{{TextButton: &TextButton{Text: "Go"}}}})}},
+			[]string{"cards[0].sections[0].widgets[0].buttons[0].textButton.onClick"},
+		},
+		{
+			"too many widgets",
+			&Message{Cards: []*Card{cardWith(manyWidgets(101)...)}},
+			[]string{"cards[0].sections"},
+		},
+		{
+			"too many cards",
+			&Message{Cards: manyCards(101)},
+			[]string{"cards"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.msg.Validate()
+			if len(tt.paths) == 0 {
+				if err != nil {
+					t.Errorf("Validate() = %v, want nil", err)
+				}
+				return
+			}
+			var ve ValidationError
+			if !errors.As(err, &ve) {
+				t.Fatalf("Validate() = %v, want a ValidationError", err)
+			}
+			if !errors.Is(err, ErrInvalidMessage) {
+				t.Errorf("Validate() does not match ErrInvalidMessage")
+			}
+			var paths []string
+			for _, fe := range ve {
+				paths = append(paths, fe.Path)
+			}
+			if !reflect.DeepEqual(paths, tt.paths) {
+				t.Errorf("paths = %q, want %q (%v)", paths, tt.paths, err)
+			}
+		})
+	}
+}
+
+func TestMessageValidateSize(t *testing.T) {
+	msg := &Message{Cards: []*Card{cardWith(manyWidgets(90)...)}}
+	if err := msg.ValidateLimits(Limits{MaxTextLength: 10, MaxMessageBytes: 1000, MaxCards: 1, MaxWidgets: 100, MaxButtons: 1}); err == nil {
+		t.Errorf("message over MaxMessageBytes is valid")
+	}
+}
+
+func TestMessageTruncate(t *testing.T) {
+	l := Limits{MaxTextLength: 5, MaxMessageBytes: 32000, MaxCards: 2, MaxWidgets: 10, MaxButtons: 2}
+	tests := []struct {
+		name string
+		msg  *Message
+		// check inspects the truncated message
+		check func(t *testing.T, m *Message)
+	}{
+		{
+			"text",
+			&Message{Text: "hello world"},
+			func(t *testing.T, m *Message) {
+				if m.Text != "hell…" {
+					t.Errorf("text = %q", m.Text)
+				}
+			},
+		},
+		{
+			"cards",
+			&Message{Cards: manyCards(3)},
+			func(t *testing.T, m *Message) {
+				if len(m.Cards) != 2 {
+					t.Errorf("%d cards, want 2", len(m.Cards))
+				}
+			},
+		},
+		{
+			"widgets",
+			&Message{Cards: []*Card{cardWith(manyWidgets(15)...)}},
+			func(t *testing.T, m *Message) {
+				widgets := m.Cards[0].Sections[0].Widgets
+				if len(widgets) != 10 {
+					t.Fatalf("%d widgets, want 10", len(widgets))
+				}
+				if note := widgets[9].TextParagraph; note == nil || note.Text != "<i>6 more not shown</i>" {
+					t.Errorf("last widget = %+v, want a note", widgets[9])
+				}
+			},
+		},
+		{
+			"buttons",
+			&Message{Cards: []*Card{cardWith(&WidgetMarkup{Buttons: []*Button{
+				{TextButton: &TextButton{Text: "a"}}, {TextButton: &TextButton{Text: "b"}}, {TextButton: &TextButton{Text: "c"}},
+			}})}},
+			func(t *testing.T, m *Message) {
+				if n := len(m.Cards[0].Sections[0].Widgets[0].Buttons); n != 2 {
+					t.Errorf("%d buttons, want 2", n)
+				}
+			},
+		},
+		{
+			"nil entries",
+			&Message{Cards: []*Card{nil, {Sections: []*Section{nil, {Widgets: append([]*WidgetMarkup{nil}, manyWidgets(20)...)}}}}},
+			func(t *testing.T, m *Message) {
+				if m.Cards[1].WidgetCount() > l.MaxWidgets {
+					t.Errorf("%d widgets, want at most %d", m.Cards[1].WidgetCount(), l.MaxWidgets)
+				}
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := JSONSize(tt.msg)
+			m := tt.msg.Truncate(l)
+			if JSONSize(tt.msg) != before {
+				t.Errorf("Truncate modified the message")
+			}
+			tt.check(t, m)
+		})
+	}
+}
+
+func TestMessageTruncateSize(t *testing.T) {
+	l := DefaultLimits
+	l.MaxMessageBytes = 2000
+	m := (&Message{Cards: []*Card{cardWith(manyWidgets(80)...)}}).Truncate(l)
+	if n := JSONSize(m); n > l.MaxMessageBytes {
+		t.Errorf("truncated message has %d bytes, want at most %d", n, l.MaxMessageBytes)
+	}
+	if err := m.ValidateLimits(l); err != nil {
+		t.Errorf("truncated message is invalid: %v", err)
+	}
+}
+
+func TestMessageTruncateNoTextLimit(t *testing.T) {
+	l := DefaultLimits
+	l.MaxTextLength = 0
+	if m := (&Message{Text: "hi"}).Truncate(l); m.Text != "" {
+		t.Errorf("text = %q, want it dropped", m.Text)
+	}
+}
+
+func TestMessageCheckPrepareInvalid(t *testing.T) {
+	msg := &Message{Cards: []*Card{nil}}
+	if _, err := CheckTruncate.prepare(msg); !errors.Is(err, ErrInvalidMessage) {
+		t.Errorf("prepare() = %v, want ErrInvalidMessage", err)
+	}
+}
+
+func manyWidgets(n int) []*WidgetMarkup {
+	widgets := make([]*WidgetMarkup, n)
+	for i := range widgets {
+		widgets[i] = keyValue(fmt.Sprintf("widget %d", i))
+	}
+	return widgets
+}
+
+func manyCards(n int) []*Card {
+	cards := make([]*Card, n)
+	for i := range cards {
+		cards[i] = cardWith(keyValue("a"))
+	}
+	return cards
+}
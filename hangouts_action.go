@@ -4,6 +4,8 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"sort"
+	"strings"
 
 	"github.com/google/go-github/v28/github"
 	"github.com/mirage20/hangouts-action/hangouts"
@@ -29,68 +31,96 @@ type Check struct {
 	TargetUrl string
 	AvatarUrl string
 	Status    Status
+	// Group is the GitHub App of a check run, or the prefix of the context
+	// of a commit status, e.g. ci/circleci
+	Group string
 }
 
+// Order in which checks are listed, failures first
+var statusOrder = []Status{StatusFailure, StatusInProgress, StatusSuccess}
+
+// Group of the commit statuses whose context has no prefix
+const defaultStatusGroup = "Commit statuses"
+
 type Checks map[Status][]Check
 
 type PullRequestFilter func(event *github.PullRequestEvent) bool
 type PullRequestChecksFilter func(event *github.PullRequestEvent, checks Checks) bool
 type HangoutsAction struct {
 	githubClient   *github.Client
-	hangoutsClient *hangouts.Client
+	hangoutsClient hangouts.Sender
+	notifiers      map[string]EventNotifier
+	routes         []*Route
+	changedFiles   map[string][]string
+	// pages counts the messages of each upserted message that was split
+	pages          map[string]int
 	SelfActionName string
+	// Templates override the default templates of some kinds of messages
+	Templates map[string]*MessageTemplate
+	// Mentions maps GitHub logins to Chat users for the mention template
+	// helper
+	Mentions map[string]string
+	// GroupChecks lists the checks by GitHub App or commit status prefix
+	GroupChecks bool
+	// LiveChecks posts the checks card as soon as the checks start and
+	// updates it whenever a check changes its state
+	LiveChecks bool
 }
 
-func (a *HangoutsAction) NotifyPullRequest(event *github.PullRequestEvent, filters ...PullRequestFilter) error {
-	var title string
+func (a *HangoutsAction) NotifyPullRequest(ctx context.Context, event *github.PullRequestEvent, filters ...PullRequestFilter) error {
+	status := StatusInProgress
+	kind := "pull_request." + *event.Action
 	switch *event.Action {
-	case "opened":
-		title = "New pull request is opened"
-	case "reopened":
-		title = "Pull request re-opened"
-	case "synchronize":
-		title = "Pull request updated"
+	case "opened", "reopened", "synchronize", "review_requested", "review_request_removed":
+	case "closed":
+		if event.PullRequest.GetMerged() {
+			status = StatusSuccess
+			kind = "pull_request.merged"
+		} else {
+			status = StatusFailure
+		}
 	default:
 		return nil
 	}
 	repo := *event.Repo.Name
 	owner := *event.Repo.Owner.Login
 	pr := event.PullRequest
-	prKey := fmt.Sprintf("%s/%s-%d", owner, repo, *event.PullRequest.Number)
+	prKey := pullRequestKey(owner, repo, *event.PullRequest.Number)
 	for _, filter := range filters {
 		if !filter(event) {
 			return nil
 		}
 	}
-	_, err := a.hangoutsClient.Send(prKey, &hangouts.Message{
-		Cards: []*hangouts.Card{
-			{
-				Header: makeCardHeader(title, *pr.Title, StatusInProgress),
-				Sections: []*hangouts.Section{
-					makeViewSection(prKey, *pr.HTMLURL),
-					makeAuthorSection(*pr.User.Login, *pr.User.HTMLURL, *pr.User.AvatarURL),
-				},
-			},
-		},
-	})
-	return err
+	sections := []*hangouts.Section{
+		makeViewSection(prKey, *pr.HTMLURL),
+		makeAuthorSection(*pr.User.Login, *pr.User.HTMLURL, *pr.User.AvatarURL),
+	}
+	switch *event.Action {
+	case "closed":
+		sections = append(sections, makeClosedSection(event))
+	case "review_requested", "review_request_removed":
+		sections = append(sections, makeReviewRequestSection(event))
+	}
+	msg, err := a.newMessage(kind, &MessageData{
+		Repository:  repositoryData(event.Repo),
+		Sender:      userData(event.Sender),
+		PullRequest: pullRequestData(pr),
+	}, status, sections...)
+	if err != nil {
+		return err
+	}
+	return a.send(ctx, pullRequestSubject("pull_request", owner, repo, pr), prKey, msg)
 }
 
-func (a *HangoutsAction) NotifyPullRequestChecks(event *github.PullRequestEvent, filters ...PullRequestChecksFilter) error {
-	switch *event.Action {
-	case "opened":
-	case "reopened":
-	case "synchronize":
-	default:
+func (a *HangoutsAction) NotifyPullRequestChecks(ctx context.Context, event *github.PullRequestEvent, filters ...PullRequestChecksFilter) error {
+	if !isPullRequestUpdate(event) {
 		return nil
 	}
 	repo := *event.Repo.Name
 	owner := *event.Repo.Owner.Login
 	ref := *event.PullRequest.Head.SHA
 	pr := event.PullRequest
-	prKey := fmt.Sprintf("%s/%s-%d", owner, repo, *event.PullRequest.Number)
-	// checks, err := a.GetChecks(context.Background(), "istio", "istio", "94f856ec6ccf5244a62e68c92d7f5dc23e0e4f09")
-	checks, err := a.GetChecks(context.Background(), owner, repo, ref)
+	checks, err := a.GetChecks(ctx, owner, repo, ref)
 	if err != nil {
 		return err
 	}
@@ -102,70 +132,151 @@ func (a *HangoutsAction) NotifyPullRequestChecks(event *github.PullRequestEvent,
 	if checks.Empty() {
 		return nil
 	}
+	return a.sendChecks(ctx, owner, repo, pr, checks)
+}
+
+func (a *HangoutsAction) sendChecks(ctx context.Context, owner, repo string, pr *github.PullRequest, checks Checks) error {
 	overallStatus := checks.OverallStatus()
-	var title string
+	var kind string
 	switch overallStatus {
 	case StatusFailure:
-		title = "Some checks were not successful"
+		kind = "checks.failure"
 	case StatusSuccess:
-		title = "All checks have passed"
+		kind = "checks.success"
 	default:
-		title = "Checks are running"
+		kind = "checks.in_progress"
 	}
-	_, err = a.hangoutsClient.Send(prKey, &hangouts.Message{
-		Cards: []*hangouts.Card{
-			{
-				Header: makeCardHeader(title, *pr.Title, overallStatus),
-				Sections: []*hangouts.Section{
-					makeViewSection(prKey, *pr.HTMLURL),
-					makeAuthorSection(*pr.User.Login, *pr.User.HTMLURL, *pr.User.AvatarURL),
-					makeChecksSection(checks),
-				},
-			},
-		},
-	})
-	return err
+	return a.sendChecksCard(ctx, owner, repo, pr, kind, checksData(checks), overallStatus, makeChecksSections(checks, checksTabUrl(pr), a.GroupChecks)...)
+}
+
+// sendChecksCard posts the checks card of the head commit of the pull request.
+// Later calls for the same commit update that card instead of posting a new
+// one, so the thread has a single up to date checks card per commit.
+func (a *HangoutsAction) sendChecksCard(ctx context.Context, owner, repo string, pr *github.PullRequest, kind string, checks *ChecksData, status Status, sections ...*hangouts.Section) error {
+	prKey := pullRequestKey(owner, repo, *pr.Number)
+	checksId := fmt.Sprintf("%s/checks/%s", prKey, pr.GetHead().GetSHA())
+	msg, err := a.newMessage(kind, &MessageData{
+		Repository:  repositoryData(pr.GetBase().GetRepo()),
+		PullRequest: pullRequestData(pr),
+		Checks:      checks,
+	}, status, append([]*hangouts.Section{
+		makeViewSection(prKey, *pr.HTMLURL),
+		makeAuthorSection(*pr.User.Login, *pr.User.HTMLURL, *pr.User.AvatarURL),
+	}, sections...)...)
+	if err != nil {
+		return err
+	}
+	return a.upsert(ctx, pullRequestSubject("checks", owner, repo, pr), checksId, prKey, msg, "cards")
 }
 
 func (a *HangoutsAction) GetChecks(ctx context.Context, owner, repo, ref string) (Checks, error) {
 	checks := make(Checks)
-	statusList, _, err := a.githubClient.Repositories.ListStatuses(ctx, owner, repo, ref, nil)
+	statusList, err := a.listLatestStatuses(ctx, owner, repo, ref)
 	if err != nil {
 		return checks, err
 	}
-	checksList, _, err := a.githubClient.Checks.ListCheckRunsForRef(ctx, owner, repo, ref, nil)
+	runs, err := a.listCheckRuns(ctx, owner, repo, ref)
 	if err != nil {
 		return checks, err
 	}
-	log.Printf("%+v\n", checksList)
+	log.Printf("%d commit statuses and %d check runs for %s", len(statusList), len(runs), ref)
 	for _, s := range statusList {
 		status := statusFromGithubStatus(s)
 		checks[status] = append(checks[status], Check{
 			Status:    status,
-			Name:      *s.Context,
-			Message:   *s.Description,
-			AvatarUrl: *s.Creator.AvatarURL,
-			TargetUrl: *s.TargetURL,
+			Name:      s.GetContext(),
+			Message:   s.GetDescription(),
+			AvatarUrl: s.GetCreator().GetAvatarURL(),
+			TargetUrl: s.GetTargetURL(),
+			Group:     statusGroup(s.GetContext()),
 		})
 	}
 
-	for _, c := range checksList.CheckRuns {
+	for _, c := range runs {
 		// Don't need to include own check
-		if *c.Name == a.SelfActionName {
+		if c.GetName() == a.SelfActionName {
 			continue
 		}
 		status := statusFromGithubCheckRun(c)
 		checks[status] = append(checks[status], Check{
 			Status:    status,
-			Name:      *c.Name,
+			Name:      c.GetName(),
 			Message:   string(status),
-			AvatarUrl: *c.App.Owner.AvatarURL,
-			TargetUrl: *c.HTMLURL,
+			AvatarUrl: c.GetApp().GetOwner().GetAvatarURL(),
+			TargetUrl: c.GetHTMLURL(),
+			Group:     c.GetApp().GetName(),
 		})
 	}
 	return checks, nil
 }
 
+// listLatestStatuses lists the latest commit status of each context. Earlier
+// statuses of a context, e.g. the pending one posted before a success, are
+// left out.
+func (a *HangoutsAction) listLatestStatuses(ctx context.Context, owner, repo, ref string) ([]*github.RepoStatus, error) {
+	var statuses []*github.RepoStatus
+	opt := &github.ListOptions{PerPage: 100}
+	for {
+		combined, resp, err := a.githubClient.Repositories.GetCombinedStatus(ctx, owner, repo, ref, opt)
+		if err != nil {
+			return nil, err
+		}
+		for i := range combined.Statuses {
+			statuses = append(statuses, &combined.Statuses[i])
+		}
+		if resp.NextPage == 0 {
+			break
+		}
+		opt.Page = resp.NextPage
+	}
+	return statuses, nil
+}
+
+// listCheckRuns lists the latest check runs of the ref
+func (a *HangoutsAction) listCheckRuns(ctx context.Context, owner, repo, ref string) ([]*github.CheckRun, error) {
+	var runs []*github.CheckRun
+	opt := &github.ListCheckRunsOptions{ListOptions: github.ListOptions{PerPage: 100}}
+	for {
+		page, resp, err := a.githubClient.Checks.ListCheckRunsForRef(ctx, owner, repo, ref, opt)
+		if err != nil {
+			return nil, err
+		}
+		runs = append(runs, page.CheckRuns...)
+		if resp.NextPage == 0 {
+			break
+		}
+		opt.Page = resp.NextPage
+	}
+	return runs, nil
+}
+
+// isPullRequestUpdate reports whether the event changed the head of the pull
+// request, which is when its checks are run.
+func isPullRequestUpdate(event *github.PullRequestEvent) bool {
+	switch *event.Action {
+	case "opened", "reopened", "synchronize":
+		return true
+	default:
+		return false
+	}
+}
+
+func pullRequestSubject(eventName, owner, repo string, pr *github.PullRequest) *Subject {
+	return &Subject{
+		Event:       eventName,
+		Owner:       owner,
+		Repo:        repo,
+		Branch:      pr.GetBase().GetRef(),
+		Labels:      labelNames(pr.Labels),
+		Author:      pr.GetUser().GetLogin(),
+		PullRequest: pr.GetNumber(),
+	}
+}
+
+func pullRequestKey(owner, repo string, number int) string {
+	return fmt.Sprintf("%s/%s-%d", owner, repo, number)
+}
+
 func imageFromStatus(s Status) string {
 	switch s {
 	case StatusSuccess:
@@ -180,17 +291,17 @@ func imageFromStatus(s Status) string {
 }
 
 func statusFromGithubCheckRun(c *github.CheckRun) Status {
-	if *c.Status != "completed" {
+	if c.GetStatus() != "completed" {
 		return StatusInProgress
 	}
-	if *c.Conclusion == "success" {
+	if c.GetConclusion() == "success" {
 		return StatusSuccess
 	}
 	return StatusFailure
 }
 
 func statusFromGithubStatus(s *github.RepoStatus) Status {
-	switch *s.State {
+	switch s.GetState() {
 	case "success":
 		return StatusSuccess
 	case "failure":
@@ -202,24 +313,112 @@ func statusFromGithubStatus(s *github.RepoStatus) Status {
 	}
 }
 
+// OverallStatus is the status of the worst check. Checks that do not exist
+// yet are in progress, as they are about to be created.
 func (c Checks) OverallStatus() Status {
-	if _, ok := c[StatusFailure]; ok {
+	if len(c[StatusFailure]) > 0 {
 		return StatusFailure
 	}
-	if _, ok := c[StatusInProgress]; ok {
+	if len(c[StatusInProgress]) > 0 || len(c[StatusSuccess]) == 0 {
 		return StatusInProgress
 	}
 	return StatusSuccess
 }
 
+// ToList lists the failed checks, then the running and the successful ones,
+// each sorted by name
 func (c Checks) ToList() []Check {
 	var checks []Check
-	for _, v := range c {
-		checks = append(checks, v...)
+	for _, status := range statusOrder {
+		checks = append(checks, c.Sorted(status)...)
 	}
 	return checks
 }
 
+// Sorted returns the checks with the given status sorted by name
+func (c Checks) Sorted(status Status) []Check {
+	checks := append([]Check(nil), c[status]...)
+	sort.SliceStable(checks, func(i, j int) bool {
+		if checks[i].Name != checks[j].Name {
+			return checks[i].Name < checks[j].Name
+		}
+		return checks[i].TargetUrl < checks[j].TargetUrl
+	})
+	return checks
+}
+
+// ChecksGroup holds the checks of a GitHub App or of a commit status prefix
+type ChecksGroup struct {
+	Name   string
+	Checks Checks
+}
+
+// Groups splits the checks by their group. Groups with failures come first,
+// then groups with running checks and then successful groups, each sorted by
+// name.
+func (c Checks) Groups() []ChecksGroup {
+	byName := make(map[string]Checks)
+	for _, check := range c.ToList() {
+		g, ok := byName[check.Group]
+		if !ok {
+			g = make(Checks)
+			byName[check.Group] = g
+		}
+		g[check.Status] = append(g[check.Status], check)
+	}
+	var groups []ChecksGroup
+	for name, checks := range byName {
+		groups = append(groups, ChecksGroup{Name: name, Checks: checks})
+	}
+	rank := func(g ChecksGroup) int {
+		for i, status := range statusOrder {
+			if g.Checks.OverallStatus() == status {
+				return i
+			}
+		}
+		return len(statusOrder)
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		if ri, rj := rank(groups[i]), rank(groups[j]); ri != rj {
+			return ri < rj
+		}
+		return groups[i].Name < groups[j].Name
+	})
+	return groups
+}
+
+// statusGroup groups commit statuses by the prefix of their context, e.g.
+// ci/circleci for ci/circleci: build
+func statusGroup(context string) string {
+	if i := strings.Index(context, ":"); i > 0 {
+		return strings.TrimSpace(context[:i])
+	}
+	return defaultStatusGroup
+}
+
+// States returns the status of each check, which is used to find out whether
+// any check has changed since an earlier poll.
+func (c Checks) States() map[string]Status {
+	states := make(map[string]Status)
+	for _, check := range c.ToList() {
+		states[check.Name+"\x00"+check.TargetUrl] = check.Status
+	}
+	return states
+}
+
+// Summary counts the checks by their status, e.g. "7/12 passed, 1 failed, 4 running"
+func (c Checks) Summary() string {
+	total := len(c.ToList())
+	summary := fmt.Sprintf("%d/%d passed", len(c[StatusSuccess]), total)
+	if n := len(c[StatusFailure]); n > 0 {
+		summary += fmt.Sprintf(", %d failed", n)
+	}
+	if n := len(c[StatusInProgress]); n > 0 {
+		summary += fmt.Sprintf(", %d running", n)
+	}
+	return summary
+}
+
 func (c Checks) Empty() bool {
 	return len(c) == 0
 }
@@ -292,35 +491,195 @@ func makeAuthorSection(username, profileUrl, avatarUrl string) *hangouts.Section
 	}
 }
 
-func makeChecksSection(checks Checks) *hangouts.Section {
-	var checksWidgets []*hangouts.WidgetMarkup
-	for _, v := range checks.ToList() {
-		checksWidgets = append(checksWidgets, &hangouts.WidgetMarkup{
-			KeyValue: &hangouts.KeyValue{
-				IconUrl:  imageFromStatus(v.Status),
-				Content:  v.Message,
-				TopLabel: v.Name,
-				Button: func() *hangouts.Button {
-					if len(v.TargetUrl) > 0 {
-						return &hangouts.Button{
-							ImageButton: &hangouts.ImageButton{
-								IconUrl: v.AvatarUrl,
-								Name:    "View",
-								OnClick: &hangouts.OnClick{
-									OpenLink: &hangouts.OpenLink{
-										Url: v.TargetUrl,
-									},
+// Checks listed in a single section. Longer lists continue in the next
+// section.
+const checksPerSection = 20
+
+// Widgets and bytes of the checks card left for the other sections and the
+// "+N more passed" rows
+const (
+	checksReservedWidgets = 5
+	checksReservedBytes   = 4000
+)
+
+// makeChecksSections lists the checks, failures first, then the running and
+// the successful checks, each sorted by name. When grouped, the checks are
+// listed by group, under a header per group. Failures and running checks
+// are always listed, over as many sections as needed. Successful checks are
+// listed as long as the card stays within the limits of Chat, and the rest
+// are summed up by a "+N more passed" row linking to the checks tab of the
+// pull request.
+func makeChecksSections(checks Checks, checksUrl string, grouped bool) []*hangouts.Section {
+	groups := []ChecksGroup{{Name: "Checks", Checks: checks}}
+	if grouped {
+		groups = checks.Groups()
+	}
+	limits := hangouts.DefaultLimits
+	maxWidgets := limits.MaxWidgets - checksReservedWidgets - len(groups)
+	maxBytes := limits.MaxMessageBytes - checksReservedBytes
+	widgets, size := 0, 0
+	for _, g := range groups {
+		for _, status := range []Status{StatusFailure, StatusInProgress} {
+			for _, c := range g.Checks[status] {
+				widgets++
+				size += hangouts.JSONSize(makeCheckWidget(c))
+			}
+		}
+	}
+	// Number of successful checks listed in each group
+	passed := make([]int, len(groups))
+fill:
+	for i, g := range groups {
+		for _, c := range g.Checks.Sorted(StatusSuccess) {
+			n := hangouts.JSONSize(makeCheckWidget(c))
+			if widgets+1 > maxWidgets || size+n > maxBytes {
+				break fill
+			}
+			widgets++
+			size += n
+			passed[i]++
+		}
+	}
+
+	var sections []*hangouts.Section
+	for i, g := range groups {
+		var groupWidgets []*hangouts.WidgetMarkup
+		for _, status := range statusOrder {
+			list := g.Checks.Sorted(status)
+			if status == StatusSuccess {
+				list = list[:passed[i]]
+			}
+			for _, c := range list {
+				groupWidgets = append(groupWidgets, makeCheckWidget(c))
+			}
+		}
+		if more := len(g.Checks[StatusSuccess]) - passed[i]; more > 0 {
+			groupWidgets = append(groupWidgets, &hangouts.WidgetMarkup{
+				KeyValue: &hangouts.KeyValue{
+					IconUrl: ImageSuccess,
+					Content: fmt.Sprintf("+%d more passed", more),
+					OnClick: &hangouts.OnClick{
+						OpenLink: &hangouts.OpenLink{
+							Url: checksUrl,
+						},
+					},
+				},
+			})
+		}
+		for first := true; len(groupWidgets) > 0; first = false {
+			n := checksPerSection
+			if n > len(groupWidgets) {
+				n = len(groupWidgets)
+			}
+			header := fmt.Sprintf("%s (continued)", g.Name)
+			if first {
+				header = fmt.Sprintf("%s (%s)", g.Name, g.Checks.Summary())
+			}
+			sections = append(sections, &hangouts.Section{
+				Header:  header,
+				Widgets: groupWidgets[:n],
+			})
+			groupWidgets = groupWidgets[n:]
+		}
+	}
+	return sections
+}
+
+func makeCheckWidget(check Check) *hangouts.WidgetMarkup {
+	kv := &hangouts.KeyValue{
+		IconUrl:  imageFromStatus(check.Status),
+		Content:  check.Message,
+		TopLabel: check.Name,
+	}
+	if len(check.TargetUrl) > 0 {
+		kv.Button = &hangouts.Button{
+			ImageButton: &hangouts.ImageButton{
+				IconUrl: check.AvatarUrl,
+				Name:    "View",
+				OnClick: &hangouts.OnClick{
+					OpenLink: &hangouts.OpenLink{
+						Url: check.TargetUrl,
+					},
+				},
+			},
+		}
+	}
+	return &hangouts.WidgetMarkup{KeyValue: kv}
+}
+
+// checksTabUrl is the url of the checks tab of the pull request
+func checksTabUrl(pr *github.PullRequest) string {
+	return pr.GetHTMLURL() + "/checks"
+}
+
+func makeClosedSection(event *github.PullRequestEvent) *hangouts.Section {
+	pr := event.PullRequest
+	if !pr.GetMerged() {
+		return &hangouts.Section{
+			Widgets: []*hangouts.WidgetMarkup{
+				makeUserWidget("Closed by", event.GetSender()),
+				{
+					KeyValue: &hangouts.KeyValue{
+						TopLabel: "Target branch",
+						Content:  pr.GetBase().GetRef(),
+					},
+				},
+			},
+		}
+	}
+	return &hangouts.Section{
+		Widgets: []*hangouts.WidgetMarkup{
+			makeUserWidget("Merged by", pr.GetMergedBy()),
+			{
+				KeyValue: &hangouts.KeyValue{
+					TopLabel: "Merged into",
+					Content:  pr.GetBase().GetRef(),
+				},
+			},
+			{
+				KeyValue: &hangouts.KeyValue{
+					TopLabel: "Merge commit",
+					Content:  shortSha(pr.GetMergeCommitSHA()),
+					Button: &hangouts.Button{
+						TextButton: &hangouts.TextButton{
+							Text: "View",
+							OnClick: &hangouts.OnClick{
+								OpenLink: &hangouts.OpenLink{
+									Url: fmt.Sprintf("%s/commit/%s", event.GetRepo().GetHTMLURL(), pr.GetMergeCommitSHA()),
 								},
 							},
-						}
-					}
-					return nil
-				}(),
+						},
+					},
+				},
 			},
-		})
+		},
 	}
-	return &hangouts.Section{
-		Header:  "Checks",
-		Widgets: checksWidgets,
+}
+
+func makeUserWidget(label string, user *github.User) *hangouts.WidgetMarkup {
+	return &hangouts.WidgetMarkup{
+		KeyValue: &hangouts.KeyValue{
+			IconUrl:  user.GetAvatarURL(),
+			TopLabel: label,
+			Content:  user.GetLogin(),
+			Button: &hangouts.Button{
+				ImageButton: &hangouts.ImageButton{
+					IconUrl: ImageGitHubAvatar,
+					Name:    "View Profile",
+					OnClick: &hangouts.OnClick{
+						OpenLink: &hangouts.OpenLink{
+							Url: user.GetHTMLURL(),
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func shortSha(sha string) string {
+	if len(sha) > 7 {
+		return sha[:7]
 	}
+	return sha
 }
@@ -0,0 +1,305 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/google/go-github/v28/github"
+	"github.com/mirage20/hangouts-action/hangouts"
+)
+
+func check(name, group string, status Status) Check {
+	return Check{Name: name, Group: group, Status: status, TargetUrl: "https://github.com/" + name}
+}
+
+func checksOf(list ...Check) Checks {
+	checks := make(Checks)
+	for _, c := range list {
+		checks[c.Status] = append(checks[c.Status], c)
+	}
+	return checks
+}
+
+func TestChecksOverallStatus(t *testing.T) {
+	tests := []struct {
+		checks Checks
+		want   Status
+	}{
+		{Checks{}, StatusInProgress},
+		{checksOf(check("a", "", StatusSuccess)), StatusSuccess},
+		{checksOf(check("a", "", StatusSuccess), check("b", "", StatusInProgress)), StatusInProgress},
+		{checksOf(check("a", "", StatusInProgress), check("b", "", StatusFailure)), StatusFailure},
+	}
+	for _, tt := range tests {
+		if got := tt.checks.OverallStatus(); got != tt.want {
+			t.Errorf("OverallStatus(%v) = %v, want %v", tt.checks, got, tt.want)
+		}
+	}
+}
+
+func TestChecksToList(t *testing.T) {
+	checks := checksOf(
+		check("lint", "", StatusSuccess),
+		check("build", "", StatusSuccess),
+		check("test", "", StatusInProgress),
+		check("e2e", "", StatusFailure),
+		check("deploy", "", StatusInProgress),
+		check("cover", "", StatusFailure),
+	)
+	want := []string{"cover", "e2e", "deploy", "test", "build", "lint"}
+	for i := 0; i < 5; i++ {
+		var names []string
+		for _, c := range checks.ToList() {
+			names = append(names, c.Name)
+		}
+		if !reflect.DeepEqual(names, want) {
+			t.Fatalf("ToList() = %q, want %q", names, want)
+		}
+	}
+}
+
+func TestChecksGroups(t *testing.T) {
+	checks := checksOf(
+		check("build", "GitHub Actions", StatusSuccess),
+		check("lint", "GitHub Actions", StatusSuccess),
+		check("ci/circleci: test", "ci/circleci", StatusInProgress),
+		check("codecov", "Codecov", StatusFailure),
+		check("deploy", "Netlify", StatusSuccess),
+		check("ci/circleci: build", "ci/circleci", StatusSuccess),
+	)
+	var names []string
+	for _, g := range checks.Groups() {
+		names = append(names, g.Name)
+	}
+	want := []string{"Codecov", "ci/circleci", "GitHub Actions", "Netlify"}
+	if !reflect.DeepEqual(names, want) {
+		t.Errorf("Groups() = %q, want %q", names, want)
+	}
+}
+
+func TestStatusGroup(t *testing.T) {
+	tests := map[string]string{
+		"ci/circleci: build": "ci/circleci",
+		"codecov/patch":      defaultStatusGroup,
+		":odd":               defaultStatusGroup,
+	}
+	for context, want := range tests {
+		if got := statusGroup(context); got != want {
+			t.Errorf("statusGroup(%q) = %q, want %q", context, got, want)
+		}
+	}
+}
+
+// sectionSummary lists the header and the names of the checks of each
+// section
+func sectionSummary(sections []*hangouts.Section) []string {
+	var summary []string
+	for _, s := range sections {
+		line := s.Header + ":"
+		for _, w := range s.Widgets {
+			line += " " + w.KeyValue.TopLabel
+		}
+		summary = append(summary, line)
+	}
+	return summary
+}
+
+func TestMakeChecksSections(t *testing.T) {
+	checks := checksOf(
+		check("lint", "GitHub Actions", StatusSuccess),
+		check("build", "GitHub Actions", StatusFailure),
+		check("ci/circleci: test", "ci/circleci", StatusSuccess),
+		check("ci/circleci: e2e", "ci/circleci", StatusInProgress),
+	)
+	tests := []struct {
+		grouped bool
+		want    []string
+	}{
+		{false, []string{
+			"Checks (2/4 passed, 1 failed, 1 running): build ci/circleci: e2e ci/circleci: test lint",
+		}},
+		{true, []string{
+			"GitHub Actions (1/2 passed, 1 failed): build lint",
+			"ci/circleci (1/2 passed, 1 running): ci/circleci: e2e ci/circleci: test",
+		}},
+	}
+	for _, tt := range tests {
+		sections := makeChecksSections(checks, "https://github.com/o/r/pull/1/checks", tt.grouped)
+		got := sectionSummary(sections)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("grouped %v: sections = %q, want %q", tt.grouped, got, tt.want)
+		}
+	}
+}
+
+func TestMakeChecksSectionsLimits(t *testing.T) {
+	var list []Check
+	for i := 0; i < 130; i++ {
+		group := []string{"GitHub Actions", "ci/circleci"}[i%2]
+		list = append(list, check(fmt.Sprintf("check %03d", i), group, StatusSuccess))
+	}
+	list = append(list, check("z failed", "ci/circleci", StatusFailure), check("a failed", "ci/circleci", StatusFailure))
+	checks := checksOf(list...)
+
+	for _, grouped := range []bool{false, true} {
+		sections := makeChecksSections(checks, "https://github.com/o/r/pull/1/checks", grouped)
+		widgets, failed, more := 0, 0, 0
+		for _, s := range sections {
+			if len(s.Widgets) > checksPerSection {
+				t.Errorf("grouped %v: section %q has %d widgets", grouped, s.Header, len(s.Widgets))
+			}
+			for _, w := range s.Widgets {
+				widgets++
+				var n int
+				switch {
+				case w.KeyValue.IconUrl == imageFromStatus(StatusFailure):
+					failed++
+				case w.KeyValue.OnClick != nil && w.KeyValue.OnClick.OpenLink.Url == "https://github.com/o/r/pull/1/checks":
+					fmt.Sscanf(w.KeyValue.Content, "+%d more passed", &n)
+					more += n
+				}
+			}
+		}
+		if failed != 2 {
+			t.Errorf("grouped %v: %d failures listed, want 2", grouped, failed)
+		}
+		if max := hangouts.DefaultLimits.MaxWidgets - checksReservedWidgets; widgets > max {
+			t.Errorf("grouped %v: %d widgets, want at most %d", grouped, widgets, max)
+		}
+		if listed := widgets - failed - len(sectionsWithMore(sections)); listed+more != 130 {
+			t.Errorf("grouped %v: %d passed listed and %d summed up, want 130", grouped, listed, more)
+		}
+		if first := sections[0].Widgets[0].KeyValue.TopLabel; first != "a failed" {
+			t.Errorf("grouped %v: first check %q, want the first failure", grouped, first)
+		}
+	}
+}
+
+func sectionsWithMore(sections []*hangouts.Section) []*hangouts.Section {
+	var with []*hangouts.Section
+	for _, s := range sections {
+		last := s.Widgets[len(s.Widgets)-1].KeyValue
+		if last.OnClick != nil && last.OnClick.OpenLink.Url == "https://github.com/o/r/pull/1/checks" {
+			with = append(with, s)
+		}
+	}
+	return with
+}
+
+// newGitHubStub serves the handlers by path and returns a client of it, and a
+// func stopping the server
+func newGitHubStub(handlers map[string]http.HandlerFunc) (*github.Client, func()) {
+	mux := http.NewServeMux()
+	for p, h := range handlers {
+		mux.HandleFunc(p, h)
+	}
+	srv := httptest.NewServer(mux)
+	client := github.NewClient(nil)
+	client.BaseURL, _ = url.Parse(srv.URL + "/")
+	return client, srv.Close
+}
+
+func TestGetChecksStatuses(t *testing.T) {
+	client, stop := newGitHubStub(map[string]http.HandlerFunc{
+		"/repos/o/r/commits/abc/status": func(w http.ResponseWriter, r *http.Request) {
+			// The latest status of each context, some without the optional
+			// fields
+			fmt.Fprint(w, `{"statuses": [
+				{"context": "ci/circleci: build", "state": "success", "target_url": "https://circleci.com/1", "description": "Passed", "creator": {"avatar_url": "https://example.com/a.png"}},
+				{"context": "deploy", "state": "pending"}
+			]}`)
+		},
+		"/repos/o/r/commits/abc/check-runs": func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, `{"total_count": 0, "check_runs": []}`)
+		},
+	})
+	defer stop()
+	a := &HangoutsAction{githubClient: client}
+	checks, err := a.GetChecks(context.Background(), "o", "r", "abc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Checks{
+		StatusSuccess:    {{Name: "ci/circleci: build", Message: "Passed", TargetUrl: "https://circleci.com/1", AvatarUrl: "https://example.com/a.png", Status: StatusSuccess, Group: "ci/circleci"}},
+		StatusInProgress: {{Name: "deploy", Status: StatusInProgress, Group: defaultStatusGroup}},
+	}
+	if !reflect.DeepEqual(checks, want) {
+		t.Errorf("GetChecks() = %+v, want %+v", checks, want)
+	}
+}
+
+// servePage writes the page of the request, linking to the next one
+func servePage(w http.ResponseWriter, r *http.Request, pages []string) {
+	page := 1
+	fmt.Sscanf(r.URL.Query().Get("page"), "%d", &page)
+	if page < len(pages) {
+		next := *r.URL
+		q := next.Query()
+		q.Set("page", fmt.Sprint(page+1))
+		next.RawQuery = q.Encode()
+		w.Header().Set("Link", fmt.Sprintf(`<http://%s%s>; rel="next"`, r.Host, next.RequestURI()))
+	}
+	fmt.Fprint(w, pages[page-1])
+}
+
+func TestGetChecksPages(t *testing.T) {
+	var statusPages, runPages []string
+	for p := 0; p < 2; p++ {
+		var statuses []string
+		for i := 0; i < 100 && p*100+i < 130; i++ {
+			statuses = append(statuses, fmt.Sprintf(`{"context": "ci/circleci: job %03d", "state": "success"}`, p*100+i))
+		}
+		statusPages = append(statusPages, fmt.Sprintf(`{"statuses": [%s]}`, strings.Join(statuses, ",")))
+	}
+	for p := 0; p < 3; p++ {
+		var runs []string
+		for i := 0; i < 100 && p*100+i < 250; i++ {
+			conclusion := "success"
+			if p == 2 && i == 10 {
+				conclusion = "failure"
+			}
+			runs = append(runs, fmt.Sprintf(`{"name": "matrix %03d", "status": "completed", "conclusion": %q, "app": {"name": "GitHub Actions"}}`, p*100+i, conclusion))
+		}
+		runPages = append(runPages, fmt.Sprintf(`{"total_count": 250, "check_runs": [%s]}`, strings.Join(runs, ",")))
+	}
+	var perPage []string
+	client, stop := newGitHubStub(map[string]http.HandlerFunc{
+		"/repos/o/r/commits/abc/status": func(w http.ResponseWriter, r *http.Request) {
+			perPage = append(perPage, r.URL.Query().Get("per_page"))
+			servePage(w, r, statusPages)
+		},
+		"/repos/o/r/commits/abc/check-runs": func(w http.ResponseWriter, r *http.Request) {
+			perPage = append(perPage, r.URL.Query().Get("per_page"))
+			servePage(w, r, runPages)
+		},
+	})
+	defer stop()
+	a := &HangoutsAction{githubClient: client}
+	checks, err := a.GetChecks(context.Background(), "o", "r", "abc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := len(checks.ToList()); n != 380 {
+		t.Errorf("%d checks, want 380", n)
+	}
+	if failed := checks[StatusFailure]; len(failed) != 1 || failed[0].Name != "matrix 210" {
+		t.Errorf("failed checks = %+v, want the one on the last page", failed)
+	}
+	if checks.OverallStatus() != StatusFailure {
+		t.Errorf("overall status = %v, want %v", checks.OverallStatus(), StatusFailure)
+	}
+	for _, n := range perPage {
+		if n != "100" {
+			t.Errorf("pages of %s items, want 100", n)
+		}
+	}
+	if len(perPage) != 5 {
+		t.Errorf("%d pages requested, want 5", len(perPage))
+	}
+}
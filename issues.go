@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+
+	"github.com/google/go-github/v28/github"
+	"github.com/mirage20/hangouts-action/hangouts"
+)
+
+type IssueFilter func(event *github.IssuesEvent) bool
+
+func (a *HangoutsAction) NotifyIssue(ctx context.Context, event *github.IssuesEvent, filters ...IssueFilter) error {
+	status := StatusInProgress
+	switch *event.Action {
+	case "opened", "reopened", "labeled", "assigned":
+	case "closed":
+		status = StatusSuccess
+	default:
+		return nil
+	}
+	repo := *event.Repo.Name
+	owner := *event.Repo.Owner.Login
+	issue := event.Issue
+	key := issueKey(owner, repo, *issue.Number)
+	for _, filter := range filters {
+		if !filter(event) {
+			return nil
+		}
+	}
+	sections := []*hangouts.Section{
+		makeViewSection(key, *issue.HTMLURL),
+		makeAuthorSection(*issue.User.Login, *issue.User.HTMLURL, *issue.User.AvatarURL),
+	}
+	if len(issue.Labels) > 0 {
+		sections = append(sections, makeLabelsSection(issue.Labels, event.Repo.GetHTMLURL()))
+	}
+	if len(issue.Assignees) > 0 {
+		sections = append(sections, makeAssigneesSection(issue.Assignees))
+	}
+	if *event.Action == "closed" {
+		sections = append(sections, &hangouts.Section{
+			Widgets: []*hangouts.WidgetMarkup{
+				makeUserWidget("Closed by", event.GetSender()),
+			},
+		})
+	}
+	var labels []string
+	for _, l := range issue.Labels {
+		labels = append(labels, l.GetName())
+	}
+	subject := &Subject{
+		Event:  "issues",
+		Owner:  owner,
+		Repo:   repo,
+		Labels: labels,
+		Author: issue.GetUser().GetLogin(),
+	}
+	msg, err := a.newMessage("issues."+*event.Action, &MessageData{
+		Repository: repositoryData(event.Repo),
+		Sender:     userData(event.Sender),
+		Issue: &IssueData{
+			Number:    issue.GetNumber(),
+			Title:     issue.GetTitle(),
+			Body:      issue.GetBody(),
+			URL:       issue.GetHTMLURL(),
+			Author:    userData(issue.GetUser()),
+			Labels:    labels,
+			CreatedAt: issue.GetCreatedAt(),
+		},
+		Label:    event.GetLabel().GetName(),
+		Assignee: userData(event.Assignee),
+	}, status, sections...)
+	if err != nil {
+		return err
+	}
+	return a.send(ctx, subject, key, msg)
+}
+
+// issueKey threads issues the same way as pull requests. Both share the same
+// number sequence in a repository, so the keys never collide.
+func issueKey(owner, repo string, number int) string {
+	return pullRequestKey(owner, repo, number)
+}
+
+// makeLabelsSection renders the labels as a row of chip like buttons which
+// open the list of issues having that label.
+func makeLabelsSection(labels []github.Label, repoUrl string) *hangouts.Section {
+	var buttons []*hangouts.Button
+	for _, l := range labels {
+		buttons = append(buttons, &hangouts.Button{
+			TextButton: &hangouts.TextButton{
+				Text: l.GetName(),
+				OnClick: &hangouts.OnClick{
+					OpenLink: &hangouts.OpenLink{
+						Url: fmt.Sprintf("%s/labels/%s", repoUrl, url.PathEscape(l.GetName())),
+					},
+				},
+			},
+		})
+	}
+	return &hangouts.Section{
+		Header: "Labels",
+		Widgets: []*hangouts.WidgetMarkup{
+			{
+				Buttons: buttons,
+			},
+		},
+	}
+}
+
+func makeAssigneesSection(assignees []*github.User) *hangouts.Section {
+	var widgets []*hangouts.WidgetMarkup
+	for _, u := range assignees {
+		widgets = append(widgets, makeUserWidget("Assignee", u))
+	}
+	return &hangouts.Section{
+		Header:  "Assignees",
+		Widgets: widgets,
+	}
+}
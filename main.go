@@ -2,12 +2,9 @@ package main
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"log"
-	"os"
-	"time"
 
 	"github.com/google/go-github/v28/github"
 	// "github.com/mirage20/hangouts-action/github"
@@ -15,86 +12,183 @@ import (
 	"golang.org/x/oauth2"
 )
 
-var (
-	webhookUrl = "unknown"
-)
-
 func main() {
-	for _, pair := range os.Environ() {
-		fmt.Println(pair)
+	cfg, err := loadConfig()
+	if err != nil {
+		log.Fatal(err)
 	}
-	githubToken := getEnvOrFail("GITHUB_TOKEN")
 	// githubRepo := getEnvOrFail("GITHUB_REPOSITORY")
-	githubEventPath := getEnvOrFail("GITHUB_EVENT_PATH")
 	// Incorrect sha for forced push
 	// githubSha := getEnvOrFail("GITHUB_SHA")
-	event := loadEvent(githubEventPath)
-
-	if skipLabel, ok := os.LookupEnv("SKIP_NOTIFY_LABEL"); ok {
-		for _, l := range event.PullRequest.Labels {
-			if *l.Name == skipLabel {
-				return
-			}
-		}
-	}
 
 	ctx := context.Background()
 	ghc := github.NewClient(oauth2.NewClient(
 		ctx,
-		oauth2.StaticTokenSource(&oauth2.Token{AccessToken: githubToken}),
+		oauth2.StaticTokenSource(&oauth2.Token{AccessToken: cfg.GithubToken}),
 	))
-	hc := hangouts.NewWebhookClient(webhookUrl)
+	hc, err := newHangoutsSenders(ctx, cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
 	ha := &HangoutsAction{
 		githubClient:   ghc,
 		hangoutsClient: hc,
-		SelfActionName: getEnvOrFail("SELF_ACTION_NAME"),
+		SelfActionName: cfg.SelfActionName,
+		GroupChecks:    cfg.GroupChecks,
+		LiveChecks:     cfg.LiveChecks,
+		Templates:      cfg.Templates,
+		Mentions:       cfg.Mentions,
+		routes:         cfg.Routes,
 	}
 
-	err := ha.NotifyPullRequest(event, func(event *github.PullRequestEvent) bool {
-		return true
+	ha.Handle("pull_request", func(ctx context.Context, e interface{}) error {
+		event := e.(*github.PullRequestEvent)
+		if skipPullRequest(ctx, ha, cfg, event.Repo, event.PullRequest) {
+			return nil
+		}
+		return notifyPullRequest(ctx, ha, cfg, event)
 	})
+	ha.Handle("pull_request_review", func(ctx context.Context, e interface{}) error {
+		event := e.(*github.PullRequestReviewEvent)
+		if skipPullRequest(ctx, ha, cfg, event.Repo, event.PullRequest) {
+			return nil
+		}
+		return ha.NotifyPullRequestReview(ctx, event)
+	})
+	ha.Handle("pull_request_review_comment", func(ctx context.Context, e interface{}) error {
+		event := e.(*github.PullRequestReviewCommentEvent)
+		if skipPullRequest(ctx, ha, cfg, event.Repo, event.PullRequest) {
+			return nil
+		}
+		return ha.NotifyPullRequestReviewComment(ctx, event)
+	})
+	ha.Handle("push", func(ctx context.Context, e interface{}) error {
+		filters := []PushFilter{func(event *github.PushEvent) bool {
+			branch, _ := branchFromRef(event.GetRef())
+			return cfg.acceptsBranch(branch) && cfg.acceptsAuthor(event.GetSender().GetLogin())
+		}}
+		if cfg.ProtectedBranchesOnly {
+			filters = append(filters, ha.ProtectedBranchFilter(ctx))
+		}
+		return ha.NotifyPush(ctx, e.(*github.PushEvent), filters...)
+	})
+	ha.Handle("release", func(ctx context.Context, e interface{}) error {
+		return ha.NotifyRelease(ctx, e.(*github.ReleaseEvent), func(event *github.ReleaseEvent) bool {
+			return cfg.acceptsAuthor(event.GetRelease().GetAuthor().GetLogin())
+		})
+	})
+	ha.Handle("issues", func(ctx context.Context, e interface{}) error {
+		return ha.NotifyIssue(ctx, e.(*github.IssuesEvent), func(event *github.IssuesEvent) bool {
+			var labels []string
+			for _, l := range event.GetIssue().Labels {
+				labels = append(labels, l.GetName())
+			}
+			return cfg.acceptsLabels(labels) && cfg.acceptsAuthor(event.GetIssue().GetUser().GetLogin())
+		})
+	})
+	ha.Handle("workflow_run", func(ctx context.Context, e interface{}) error {
+		event := e.(*WorkflowRunEvent)
+		return ha.NotifyWorkflowRunCompleted(ctx, event, func(pr *github.PullRequest, checks Checks) bool {
+			return !skipPullRequest(ctx, ha, cfg, event.Repo, pr)
+		})
+	})
+	ha.Handle("check_suite", func(ctx context.Context, e interface{}) error {
+		event := e.(*github.CheckSuiteEvent)
+		return ha.NotifyCheckSuiteCompleted(ctx, event, func(pr *github.PullRequest, checks Checks) bool {
+			return !skipPullRequest(ctx, ha, cfg, event.Repo, pr)
+		})
+	})
+
+	if len(cfg.Events) > 0 && !containsString(cfg.Events, cfg.GithubEventName) {
+		log.Printf("event %q is not configured to be notified", cfg.GithubEventName)
+		return
+	}
+	err = ha.Dispatch(ctx, cfg.GithubEventName, loadEvent(cfg.GithubEventPath))
 	if err != nil {
 		log.Fatal(err)
 	}
+}
 
-	for {
-		time.Sleep(15 * time.Second)
-		done := false
-		err = ha.NotifyPullRequestChecks(event, func(event *github.PullRequestEvent, checks Checks) bool {
-			if checks.OverallStatus() == StatusFailure || checks.OverallStatus() == StatusSuccess {
-				done = true
-				return true
-			}
-			// checks are in progress. no need to send message
-			return false
-		})
+// newHangoutsSenders creates the senders of the default destination and of
+// the routes. Spaces are posted to through the Chat API and webhooks
+// directly. The default sender is nil when only routes are configured.
+func newHangoutsSenders(ctx context.Context, cfg *Config) (hangouts.Sender, error) {
+	retry := hangouts.DefaultRetryPolicy
+	retry.MaxRetries = cfg.SendRetries
+	check := hangouts.CheckValidate
+	if cfg.TruncateMessages {
+		check = hangouts.CheckTruncate
+	}
+	opts := []hangouts.ClientOption{
+		hangouts.WithTimeout(cfg.SendTimeout),
+		hangouts.WithRetryPolicy(retry),
+		hangouts.WithMessageCheck(check),
+	}
+	var api *hangouts.APIClient
+	if len(cfg.ServiceAccountKey) > 0 {
+		var err error
+		api, err = hangouts.NewAPIClient(ctx, cfg.ServiceAccountKey, opts...)
 		if err != nil {
-			log.Fatal(err)
+			return nil, err
+		}
+	}
+	spaceSender := func(space string) (hangouts.Sender, error) {
+		if api == nil {
+			return nil, fmt.Errorf("cannot post to %s without a service account key", space)
 		}
-		if done {
-			break
+		return api.Space(space), nil
+	}
+	for _, r := range cfg.Routes {
+		if len(r.Space) > 0 {
+			sender, err := spaceSender(r.Space)
+			if err != nil {
+				return nil, fmt.Errorf("route %s: %v", r.Name, err)
+			}
+			r.sender = sender
+		} else {
+			r.sender = hangouts.NewWebhookClient(r.Webhook, opts...)
 		}
 	}
+	switch {
+	case len(cfg.Space) > 0:
+		return spaceSender(cfg.Space)
+	case len(cfg.WebhookUrl) > 0:
+		return hangouts.NewWebhookClient(cfg.WebhookUrl, opts...), nil
+	default:
+		return nil, nil
+	}
 }
 
-func getEnvOrFail(key string) string {
-	v, ok := os.LookupEnv(key)
-	if !ok {
-		log.Fatalf("environment variable %s not provided", key)
+func notifyPullRequest(ctx context.Context, ha *HangoutsAction, cfg *Config, event *github.PullRequestEvent) error {
+	err := ha.NotifyPullRequest(ctx, event, func(event *github.PullRequestEvent) bool {
+		return true
+	})
+	if err != nil {
+		return err
+	}
+	if !cfg.WaitForChecks || !isPullRequestUpdate(event) {
+		return nil
 	}
-	return v
+	return ha.WaitForPullRequestChecks(ctx, event, cfg.Poll)
 }
 
-func loadEvent(eventPath string) *github.PullRequestEvent {
+// skipPullRequest reports whether the pull request is filtered out by its
+// labels, author, base branch, draft state or changed files
+func skipPullRequest(ctx context.Context, ha *HangoutsAction, cfg *Config, repo *github.Repository, pr *github.PullRequest) bool {
+	if !cfg.acceptsLabels(labelNames(pr.Labels)) || !cfg.acceptsAuthor(pr.GetUser().GetLogin()) {
+		return true
+	}
+	if !cfg.acceptsBranch(pr.GetBase().GetRef()) || (pr.GetDraft() && !cfg.Drafts) {
+		return true
+	}
+	return cfg.Paths != nil && !ha.MatchesChangedFiles(ctx, repo.GetOwner().GetLogin(), repo.GetName(), pr, cfg.Paths)
+}
+
+func loadEvent(eventPath string) []byte {
 	eventData, err := ioutil.ReadFile(eventPath)
 	if err != nil {
 		log.Fatal(err)
 	}
 	log.Println(string(eventData))
-	event := &github.PullRequestEvent{}
-	err = json.Unmarshal(eventData, event)
-	if err != nil {
-		log.Fatal(err)
-	}
-	return event
+	return eventData
 }
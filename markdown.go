@@ -0,0 +1,150 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// Card text paragraphs only support a handful of HTML tags (<b>, <i>, <u>,
+// <strike>, <font color>, <a href> and <br>), so GitHub Markdown is reduced to
+// what can be expressed with them. Anything else is kept as plain text.
+var (
+	mdHeading    = regexp.MustCompile(`^#{1,6}\s+(.*?)\s*#*$`)
+	mdListItem   = regexp.MustCompile(`^(\s*)[-*+]\s+(.*)$`)
+	mdTaskItem   = regexp.MustCompile(`^\[([ xX])\]\s+(.*)$`)
+	mdQuote      = regexp.MustCompile(`^&gt;\s?(.*)$`)
+	mdRule       = regexp.MustCompile(`^\s*([-*_])(\s*([-*_])){2,}\s*$`)
+	mdCodeSpan   = regexp.MustCompile("`([^`]+)`")
+	mdImage      = regexp.MustCompile(`!\[([^\]]*)\]` + mdLinkTarget)
+	mdLink       = regexp.MustCompile(`\[([^\]]+)\]` + mdLinkTarget)
+	mdBold       = regexp.MustCompile(`\*\*(.+?)\*\*|__(.+?)__`)
+	mdItalic     = regexp.MustCompile(`\*([^*\s][^*]*?)\*|\b_([^_\s][^_]*?)_\b`)
+	mdStrike     = regexp.MustCompile(`~~(.+?)~~`)
+	mdCodeMarker = regexp.MustCompile("\x00(\\d+)\x00")
+)
+
+// mdLinkTarget matches the url of a link and its optional title. The url may
+// contain balanced parentheses, e.g.
+// https://en.wikipedia.org/wiki/Go_(programming_language).
+const mdLinkTarget = `\(((?:[^()\s]|\([^()\s]*\))+)(?:\s+[^)]*)?\)`
+
+const mdCodeColor = "#24292e"
+
+// markdownToChat converts GitHub flavoured Markdown into the formatted text
+// understood by hangouts.TextParagraph.
+func markdownToChat(md string) string {
+	var lines []string
+	inCode := false
+	for _, line := range strings.Split(strings.Replace(md, "\r\n", "\n", -1), "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), "```") {
+			inCode = !inCode
+			continue
+		}
+		if inCode {
+			lines = append(lines, fmt.Sprintf(`<font color="%s">%s</font>`, mdCodeColor, html.EscapeString(line)))
+			continue
+		}
+		lines = append(lines, markdownLineToChat(line))
+	}
+	return strings.TrimSpace(strings.Join(trimBlankLines(lines), "<br>"))
+}
+
+func markdownLineToChat(line string) string {
+	if mdRule.MatchString(line) {
+		return ""
+	}
+	line = html.EscapeString(line)
+	if m := mdHeading.FindStringSubmatch(line); m != nil {
+		return fmt.Sprintf("<b>%s</b>", markdownInline(m[1]))
+	}
+	if m := mdQuote.FindStringSubmatch(line); m != nil {
+		return fmt.Sprintf("<i>%s</i>", markdownInline(m[1]))
+	}
+	if m := mdListItem.FindStringSubmatch(line); m != nil {
+		indent := strings.Repeat("&nbsp;&nbsp;", len(strings.Replace(m[1], "\t", "  ", -1))/2)
+		item := m[2]
+		if t := mdTaskItem.FindStringSubmatch(item); t != nil {
+			mark := "☐"
+			if t[1] != " " {
+				mark = "☑"
+			}
+			return fmt.Sprintf("%s%s %s", indent, mark, markdownInline(t[2]))
+		}
+		return fmt.Sprintf("%s• %s", indent, markdownInline(item))
+	}
+	return markdownInline(line)
+}
+
+// markdownInline converts the inline formatting of an already HTML escaped
+// line. Code spans are set aside first so that their content is not touched.
+func markdownInline(s string) string {
+	var code []string
+	s = mdCodeSpan.ReplaceAllStringFunc(s, func(m string) string {
+		code = append(code, mdCodeSpan.FindStringSubmatch(m)[1])
+		return fmt.Sprintf("\x00%d\x00", len(code)-1)
+	})
+	s = mdImage.ReplaceAllStringFunc(s, mdLinkReplacer(mdImage))
+	s = mdLink.ReplaceAllStringFunc(s, mdLinkReplacer(mdLink))
+	s = mdBold.ReplaceAllString(s, "<b>$1$2</b>")
+	s = mdItalic.ReplaceAllString(s, "<i>$1$2</i>")
+	s = mdStrike.ReplaceAllString(s, "<strike>$1</strike>")
+	return mdCodeMarker.ReplaceAllStringFunc(s, func(m string) string {
+		var i int
+		fmt.Sscanf(mdCodeMarker.FindStringSubmatch(m)[1], "%d", &i)
+		return fmt.Sprintf(`<font color="%s">%s</font>`, mdCodeColor, code[i])
+	})
+}
+
+// mdLinkReplacer turns the links matched by re into <a> tags. Only absolute
+// http and https links are kept, anything else, e.g. javascript: or relative
+// links, is replaced by its text.
+func mdLinkReplacer(re *regexp.Regexp) func(string) string {
+	return func(m string) string {
+		sm := re.FindStringSubmatch(m)
+		u, err := url.Parse(html.UnescapeString(sm[2]))
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || len(u.Host) == 0 {
+			return sm[1]
+		}
+		return fmt.Sprintf(`<a href="%s">%s</a>`, sm[2], sm[1])
+	}
+}
+
+// trimBlankLines collapses runs of blank lines into one
+func trimBlankLines(lines []string) []string {
+	var out []string
+	for i, l := range lines {
+		if len(strings.TrimSpace(l)) == 0 && (i == 0 || len(strings.TrimSpace(lines[i-1])) == 0) {
+			continue
+		}
+		out = append(out, l)
+	}
+	return out
+}
+
+// truncateMarkdown cuts md at a line boundary so that it is at most n
+// characters long, which keeps the Markdown structure of the kept lines
+// intact.
+func truncateMarkdown(md string, n int) (string, bool) {
+	if len([]rune(md)) <= n {
+		return md, false
+	}
+	var b strings.Builder
+	for _, line := range strings.Split(md, "\n") {
+		if len([]rune(b.String()))+len([]rune(line))+1 > n {
+			break
+		}
+		b.WriteString(line)
+		b.WriteString("\n")
+	}
+	if b.Len() == 0 {
+		if n < 1 {
+			return "", true
+		}
+		// Room for the ellipsis
+		return excerpt(md, n-1), true
+	}
+	return b.String(), true
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestMarkdownToChat(t *testing.T) {
+	tests := []struct {
+		md   string
+		want string
+	}{
+		{"# Release 1.0 #", "<b>Release 1.0</b>"},
+		{"> quoted *text*", "<i>quoted <i>text</i></i>"},
+		{"- one\n  - two", "• one<br>&nbsp;&nbsp;• two"},
+		{"- [x] done\n- [ ] todo", "☑ done<br>☐ todo"},
+		{"**bold**, __bold__, *it*, _it_ and ~~gone~~", "<b>bold</b>, <b>bold</b>, <i>it</i>, <i>it</i> and <strike>gone</strike>"},
+		{"snake_case_name", "snake_case_name"},
+		{"a\n\n\n\nb\n---\nc", "a<br><br>b<br><br>c"},
+		{"`a <b> **c**`", `<font color="#24292e">a &lt;b&gt; **c**</font>`},
+		{"```\nx := <-c\n```", `<font color="#24292e">x := &lt;-c</font>`},
+		{"<script>alert(1)</script>", "&lt;script&gt;alert(1)&lt;/script&gt;"},
+		{"[docs](https://example.com/a?b=1&c=2)", `<a href="https://example.com/a?b=1&amp;c=2">docs</a>`},
+		{`[docs](https://example.com "Title")`, `<a href="https://example.com">docs</a>`},
+		{"[Go](https://en.wikipedia.org/wiki/Go_(programming_language)) rocks", `<a href="https://en.wikipedia.org/wiki/Go_(programming_language)">Go</a> rocks`},
+		{"(see [docs](http://example.com))", `(see <a href="http://example.com">docs</a>)`},
+		{"![logo](https://example.com/logo.png)", `<a href="https://example.com/logo.png">logo</a>`},
+		{"[x](javascript:alert(1))", "x"},
+		{"[x](JavaScript:alert(1))", "x"},
+		{"[x](data:text/html;base64,PHNjcmlwdD4=)", "x"},
+		{"[x](vbscript:msgbox)", "x"},
+		{"[guide](docs/guide.md)", "guide"},
+		{"[x](//example.com)", "x"},
+		{"![img](javascript:alert(1))", "img"},
+		{"line\r\nbreak", "line<br>break"},
+	}
+	for _, tt := range tests {
+		if got := markdownToChat(tt.md); got != tt.want {
+			t.Errorf("markdownToChat(%q) = %q, want %q", tt.md, got, tt.want)
+		}
+	}
+}
+
+func TestTruncateMarkdown(t *testing.T) {
+	tests := []struct {
+		md        string
+		n         int
+		want      string
+		truncated bool
+	}{
+		{"short", 10, "short", false},
+		{"exactly10!", 10, "exactly10!", false},
+		{"one\ntwo\nthree", 9, "one\ntwo\n", true},
+		{"one\ntwo\nthree", 5, "one\n", true},
+		{"a single long line", 9, "a single…", true},
+		{"ünïcödé ünïcödé", 8, "ünïcödé…", true},
+		{"anything", 0, "", true},
+	}
+	for _, tt := range tests {
+		got, truncated := truncateMarkdown(tt.md, tt.n)
+		if got != tt.want || truncated != tt.truncated {
+			t.Errorf("truncateMarkdown(%q, %d) = %q, %v, want %q, %v", tt.md, tt.n, got, truncated, tt.want, tt.truncated)
+		}
+		if n := utf8.RuneCountInString(got); n > tt.n && tt.truncated {
+			t.Errorf("truncateMarkdown(%q, %d) has %d characters", tt.md, tt.n, n)
+		}
+	}
+	long := strings.Repeat("- item\n", 1000)
+	if got, _ := truncateMarkdown(long, 2000); !strings.HasSuffix(got, "- item\n") {
+		t.Errorf("truncateMarkdown cut a line: %q", got[len(got)-10:])
+	}
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+
+	"github.com/google/go-github/v28/github"
+)
+
+// PathMatcher matches file paths against include and exclude patterns written
+// the way CODEOWNERS and .gitignore patterns are:
+//
+//	*.js         files with the extension anywhere in the repository
+//	docs/        everything under any docs directory
+//	/docs/       everything under the docs directory at the root
+//	apps/*.go    files directly under apps at the root, as a pattern with a
+//	             slash in the middle is relative to the root
+//	docs/*       files directly under docs at the root, not the nested ones
+//	**/test/**   everything under any test directory
+type PathMatcher struct {
+	include []*regexp.Regexp
+	exclude []*regexp.Regexp
+}
+
+// NewPathMatcher compiles the patterns. No include patterns include all
+// paths.
+func NewPathMatcher(include, exclude []string) (*PathMatcher, error) {
+	m := &PathMatcher{}
+	for _, p := range include {
+		re, err := compilePathPattern(p)
+		if err != nil {
+			return nil, err
+		}
+		m.include = append(m.include, re)
+	}
+	for _, p := range exclude {
+		re, err := compilePathPattern(p)
+		if err != nil {
+			return nil, err
+		}
+		m.exclude = append(m.exclude, re)
+	}
+	return m, nil
+}
+
+// MatchAny reports whether any of the files is included and not excluded
+func (m *PathMatcher) MatchAny(files []string) bool {
+	for _, f := range files {
+		if m.Match(f) {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *PathMatcher) Match(file string) bool {
+	for _, re := range m.exclude {
+		if re.MatchString(file) {
+			return false
+		}
+	}
+	if len(m.include) == 0 {
+		return true
+	}
+	for _, re := range m.include {
+		if re.MatchString(file) {
+			return true
+		}
+	}
+	return false
+}
+
+func compilePathPattern(pattern string) (*regexp.Regexp, error) {
+	p := strings.TrimSpace(pattern)
+	if len(p) == 0 || p == "/" {
+		return nil, fmt.Errorf("invalid path pattern %q", pattern)
+	}
+	anchored := strings.HasPrefix(p, "/") || strings.Contains(strings.TrimSuffix(p, "/"), "/")
+	dirOnly := strings.HasSuffix(p, "/")
+	p = strings.TrimSuffix(strings.TrimPrefix(p, "/"), "/")
+
+	var b strings.Builder
+	if anchored {
+		b.WriteString("^")
+	} else {
+		b.WriteString("^(?:.*/)?")
+	}
+	for i := 0; i < len(p); i++ {
+		switch {
+		case strings.HasPrefix(p[i:], "**/"):
+			b.WriteString("(?:.*/)?")
+			i += 2
+		case strings.HasPrefix(p[i:], "**"):
+			b.WriteString(".*")
+			i++
+		case p[i] == '*':
+			b.WriteString("[^/]*")
+		case p[i] == '?':
+			b.WriteString("[^/]")
+		default:
+			b.WriteString(regexp.QuoteMeta(p[i : i+1]))
+		}
+	}
+	// A pattern matching a directory matches everything under it. A last
+	// segment with wildcards, as in docs/*, only matches the entries of the
+	// directory, not the files nested deeper.
+	last := p[strings.LastIndex(p, "/")+1:]
+	switch {
+	case dirOnly:
+		b.WriteString("/.*$")
+	case strings.ContainsAny(last, "*?"):
+		b.WriteString("$")
+	default:
+		b.WriteString("(?:/.*)?$")
+	}
+	re, err := regexp.Compile(b.String())
+	if err != nil {
+		return nil, fmt.Errorf("invalid path pattern %q: %v", pattern, err)
+	}
+	return re, nil
+}
+
+// ChangedFiles lists the files changed by the pull request, paging through
+// all of them. The list is cached for the run, as several notifications of
+// the same pull request may need it.
+func (a *HangoutsAction) ChangedFiles(ctx context.Context, owner, repo string, number int) ([]string, error) {
+	key := pullRequestKey(owner, repo, number)
+	if files, ok := a.changedFiles[key]; ok {
+		return files, nil
+	}
+	var files []string
+	opt := &github.ListOptions{PerPage: 100}
+	for {
+		page, resp, err := a.githubClient.PullRequests.ListFiles(ctx, owner, repo, number, opt)
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range page {
+			files = append(files, f.GetFilename())
+		}
+		if resp.NextPage == 0 {
+			break
+		}
+		opt.Page = resp.NextPage
+	}
+	if a.changedFiles == nil {
+		a.changedFiles = make(map[string][]string)
+	}
+	a.changedFiles[key] = files
+	return files, nil
+}
+
+// MatchesChangedFiles reports whether the pull request changes any file
+// matched by m. When the files cannot be listed, the pull request is assumed
+// to match, as a spurious notification is better than a missing one.
+func (a *HangoutsAction) MatchesChangedFiles(ctx context.Context, owner, repo string, pr *github.PullRequest, m *PathMatcher) bool {
+	files, err := a.ChangedFiles(ctx, owner, repo, pr.GetNumber())
+	if err != nil {
+		log.Printf("cannot list the files changed by pull request #%d: %v", pr.GetNumber(), err)
+		return true
+	}
+	return m.MatchAny(files)
+}
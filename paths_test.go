@@ -0,0 +1,88 @@
+package main
+
+import "testing"
+
+func TestCompilePathPattern(t *testing.T) {
+	tests := []struct {
+		pattern string
+		path    string
+		want    bool
+	}{
+		{"*.go", "main.go", true},
+		{"*.go", "hangouts/types.go", true},
+		{"*.go", "main.golden", false},
+		{"docs/", "docs/index.md", true},
+		{"docs/", "site/docs/index.md", true},
+		{"docs/", "docs", false},
+		{"docs/", "documentation/index.md", false},
+		{"/docs/", "docs/guide/index.md", true},
+		{"/docs/", "site/docs/index.md", false},
+		{"apps/*.go", "apps/main.go", true},
+		{"apps/*.go", "apps/cmd/main.go", false},
+		{"apps/*.go", "src/apps/main.go", false},
+		{"docs/*", "docs/getting-started.md", true},
+		{"docs/*", "docs/build-app/troubleshooting.md", false},
+		{"docs/*", "site/docs/index.md", false},
+		{"/docs/*/", "docs/build-app/troubleshooting.md", true},
+		{"docs/**", "docs/build-app/troubleshooting.md", true},
+		{"*.go", "main.go/x.txt", false},
+		{"**/testdata/**", "testdata/a.json", true},
+		{"**/testdata/**", "pkg/x/testdata/a.json", true},
+		{"**/testdata/**", "pkg/testdata.go", false},
+		{"README.md", "README.md", true},
+		{"README.md", "docs/README.md", true},
+		{"/README.md", "docs/README.md", false},
+		{"a?.txt", "ab.txt", true},
+		{"a?.txt", "a/.txt", false},
+		{"src", "src/main.go", true},
+		{"file.go", "file_go", false},
+	}
+	for _, tt := range tests {
+		re, err := compilePathPattern(tt.pattern)
+		if err != nil {
+			t.Errorf("compilePathPattern(%q): %v", tt.pattern, err)
+			continue
+		}
+		if got := re.MatchString(tt.path); got != tt.want {
+			t.Errorf("pattern %q matching %q = %v, want %v (regexp %s)", tt.pattern, tt.path, got, tt.want, re)
+		}
+	}
+}
+
+func TestCompilePathPatternInvalid(t *testing.T) {
+	for _, pattern := range []string{"", "  ", "/"} {
+		if _, err := compilePathPattern(pattern); err == nil {
+			t.Errorf("compilePathPattern(%q) did not fail", pattern)
+		}
+	}
+}
+
+func TestPathMatcher(t *testing.T) {
+	m, err := NewPathMatcher([]string{"/src/"}, []string{"*.md"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		files []string
+		want  bool
+	}{
+		{[]string{"src/main.go"}, true},
+		{[]string{"src/README.md"}, false},
+		{[]string{"src/README.md", "src/main.go"}, true},
+		{[]string{"docs/index.html"}, false},
+		{nil, false},
+	}
+	for _, tt := range tests {
+		if got := m.MatchAny(tt.files); got != tt.want {
+			t.Errorf("MatchAny(%q) = %v, want %v", tt.files, got, tt.want)
+		}
+	}
+
+	all, err := NewPathMatcher(nil, []string{"docs/"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !all.Match("main.go") || all.Match("docs/index.md") {
+		t.Errorf("matcher without include patterns should match everything but the excluded paths")
+	}
+}
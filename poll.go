@@ -0,0 +1,165 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"reflect"
+	"time"
+
+	"github.com/google/go-github/v28/github"
+	"github.com/mirage20/hangouts-action/hangouts"
+)
+
+// PollPolicy controls how often the checks are polled while waiting for them
+// to complete and for how long.
+type PollPolicy struct {
+	// Interval is the wait before the first poll
+	Interval time.Duration
+	// MaxInterval caps the wait between two polls
+	MaxInterval time.Duration
+	// Multiplier grows the wait after each poll
+	Multiplier float64
+	// Jitter shortens each wait by a random fraction of it, up to this one
+	Jitter float64
+	// Timeout is the overall time given to the checks to complete
+	Timeout time.Duration
+}
+
+// Backoff returns the wait before the given poll, counting from zero
+func (p PollPolicy) Backoff(attempt int) time.Duration {
+	return hangouts.Backoff{
+		Initial:    p.Interval,
+		Max:        p.MaxInterval,
+		Multiplier: p.Multiplier,
+		Jitter:     p.Jitter,
+	}.Wait(attempt)
+}
+
+// Checks are created by GitHub shortly after the pull request is updated.
+// When there are still none after this long, there are none to wait for.
+const checksStartGrace = 2 * time.Minute
+
+// WaitForPullRequestChecks polls the checks of the pull request until all of
+// them are complete and notifies the result. When the checks are not complete
+// by the timeout, the checks that are still pending are notified instead.
+// When the pull request has no checks, polling stops after checksStartGrace
+// without notifying anything.
+func (a *HangoutsAction) WaitForPullRequestChecks(ctx context.Context, event *github.PullRequestEvent, policy PollPolicy) error {
+	owner := *event.Repo.Owner.Login
+	repo := *event.Repo.Name
+	start := time.Now()
+	deadline := start.Add(policy.Timeout)
+	var checks Checks
+	var states map[string]Status
+	if a.LiveChecks {
+		var err error
+		checks, err = a.GetChecks(ctx, owner, repo, *event.PullRequest.Head.SHA)
+		if err != nil {
+			return err
+		}
+		if err := a.sendChecksRunning(ctx, owner, repo, event.PullRequest, checks); err != nil {
+			return err
+		}
+		states = checks.States()
+	}
+	for attempt := 0; ; attempt++ {
+		wait := policy.Backoff(attempt)
+		if remaining := time.Until(deadline); wait > remaining {
+			wait = remaining
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(wait):
+		}
+		done := false
+		err := a.NotifyPullRequestChecks(ctx, event, func(event *github.PullRequestEvent, c Checks) bool {
+			checks = c
+			if c.Empty() {
+				// The checks may not have been created yet
+				return false
+			}
+			if c.OverallStatus() == StatusFailure || c.OverallStatus() == StatusSuccess {
+				done = true
+				return true
+			}
+			if a.LiveChecks && !reflect.DeepEqual(states, c.States()) {
+				states = c.States()
+				return true
+			}
+			// checks are in progress. no need to send message
+			return false
+		})
+		if err != nil {
+			return err
+		}
+		if done {
+			return nil
+		}
+		if checks.Empty() && (time.Since(start) >= checksStartGrace || !time.Now().Before(deadline)) {
+			return a.stopWithoutChecks(ctx, owner, repo, event.PullRequest, checks)
+		}
+		if !time.Now().Before(deadline) {
+			return a.sendChecksTimedOut(ctx, owner, repo, event.PullRequest, checks, policy.Timeout)
+		}
+	}
+}
+
+// sendChecksRunning posts the checks card before the checks complete. The
+// checks may not have been created yet at that point.
+func (a *HangoutsAction) sendChecksRunning(ctx context.Context, owner, repo string, pr *github.PullRequest, checks Checks) error {
+	if checks.Empty() {
+		return a.sendChecksCard(ctx, owner, repo, pr, "checks.in_progress", checksData(checks), StatusInProgress, &hangouts.Section{
+			Widgets: []*hangouts.WidgetMarkup{
+				{
+					TextParagraph: &hangouts.TextParagraph{
+						Text: "Waiting for the checks to start",
+					},
+				},
+			},
+		})
+	}
+	return a.sendChecks(ctx, owner, repo, pr, checks)
+}
+
+// stopWithoutChecks ends the polling of a pull request that has no checks.
+// Nothing is notified, except that the card posted by live checks no longer
+// waits for the checks to start.
+func (a *HangoutsAction) stopWithoutChecks(ctx context.Context, owner, repo string, pr *github.PullRequest, checks Checks) error {
+	log.Printf("no checks were started for %s, nothing to wait for", pr.GetHead().GetSHA())
+	if !a.LiveChecks {
+		return nil
+	}
+	data := checksData(checks)
+	data.Status = StatusSuccess
+	return a.sendChecksCard(ctx, owner, repo, pr, "checks.success", data, StatusSuccess, &hangouts.Section{
+		Widgets: []*hangouts.WidgetMarkup{
+			{
+				TextParagraph: &hangouts.TextParagraph{
+					Text: "No checks were started",
+				},
+			},
+		},
+	})
+}
+
+func (a *HangoutsAction) sendChecksTimedOut(ctx context.Context, owner, repo string, pr *github.PullRequest, checks Checks, timeout time.Duration) error {
+	// The notice has a section of its own, as the sections of the pending
+	// checks have their headers
+	sections := []*hangouts.Section{
+		{
+			Widgets: []*hangouts.WidgetMarkup{
+				{
+					TextParagraph: &hangouts.TextParagraph{
+						Text: fmt.Sprintf("Checks not completed within %s", timeout),
+					},
+				},
+			},
+		},
+	}
+	pending := makeChecksSections(Checks{StatusInProgress: checks[StatusInProgress]}, checksTabUrl(pr), a.GroupChecks)
+	data := checksData(checks)
+	data.Timeout = timeout
+	return a.sendChecksCard(ctx, owner, repo, pr, "checks.timed_out", data, StatusFailure, append(sections, pending...)...)
+}
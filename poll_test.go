@@ -0,0 +1,183 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v28/github"
+	"github.com/mirage20/hangouts-action/hangouts"
+)
+
+// fakeSender records the messages sent, and the upserted ones by id. It
+// fails every send with err when set.
+type fakeSender struct {
+	sent     []*hangouts.Message
+	upserted map[string][]*hangouts.Message
+	err      error
+}
+
+func (s *fakeSender) Send(threadKey string, msg *hangouts.Message) (*hangouts.Message, error) {
+	return s.SendContext(context.Background(), threadKey, msg)
+}
+
+func (s *fakeSender) SendContext(ctx context.Context, threadKey string, msg *hangouts.Message) (*hangouts.Message, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	s.sent = append(s.sent, msg)
+	return msg, nil
+}
+
+func (s *fakeSender) Update(name string, msg *hangouts.Message, updateMask ...string) (*hangouts.Message, error) {
+	return s.UpdateContext(context.Background(), name, msg, updateMask...)
+}
+
+func (s *fakeSender) UpdateContext(ctx context.Context, name string, msg *hangouts.Message, updateMask ...string) (*hangouts.Message, error) {
+	return nil, fmt.Errorf("unexpected update of %s", name)
+}
+
+func (s *fakeSender) Upsert(id, threadKey string, msg *hangouts.Message, updateMask ...string) (*hangouts.Message, error) {
+	return s.UpsertContext(context.Background(), id, threadKey, msg, updateMask...)
+}
+
+func (s *fakeSender) UpsertContext(ctx context.Context, id, threadKey string, msg *hangouts.Message, updateMask ...string) (*hangouts.Message, error) {
+	if s.upserted == nil {
+		s.upserted = make(map[string][]*hangouts.Message)
+	}
+	s.upserted[id] = append(s.upserted[id], msg)
+	return msg, nil
+}
+
+func pullRequestEvent() *github.PullRequestEvent {
+	return &github.PullRequestEvent{
+		Action: github.String("opened"),
+		Repo: &github.Repository{
+			Name:  github.String("r"),
+			Owner: &github.User{Login: github.String("o")},
+		},
+		PullRequest: &github.PullRequest{
+			Number:  github.Int(1),
+			Title:   github.String("Fix"),
+			HTMLURL: github.String("https://github.com/o/r/pull/1"),
+			Head:    &github.PullRequestBranch{SHA: github.String("abc")},
+			User: &github.User{
+				Login:     github.String("octocat"),
+				HTMLURL:   github.String("https://github.com/octocat"),
+				AvatarURL: github.String("https://github.com/octocat.png"),
+			},
+		},
+	}
+}
+
+// checksStub serves the check runs returned by runs on each poll, and no
+// commit statuses
+func checksStub(runs func(poll int) string) (*github.Client, func()) {
+	poll := 0
+	return newGitHubStub(map[string]http.HandlerFunc{
+		"/repos/o/r/commits/abc/status": func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, `{"statuses": []}`)
+		},
+		"/repos/o/r/commits/abc/check-runs": func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprintf(w, `{"check_runs": [%s]}`, runs(poll))
+			poll++
+		},
+	})
+}
+
+func cardTitles(msgs []*hangouts.Message) []string {
+	var titles []string
+	for _, m := range msgs {
+		titles = append(titles, m.Cards[0].Header.Title)
+	}
+	return titles
+}
+
+func TestWaitForPullRequestChecks(t *testing.T) {
+	policy := PollPolicy{Interval: time.Millisecond, Multiplier: 1, Timeout: 50 * time.Millisecond}
+	const build = `{"name": "build", "status": "in_progress"}`
+	tests := []struct {
+		name string
+		live bool
+		runs func(poll int) string
+		// want lists the titles of the versions of the checks card
+		want []string
+	}{
+		{
+			name: "no checks",
+			runs: func(int) string { return "" },
+		},
+		{
+			name: "no checks with live checks",
+			live: true,
+			runs: func(int) string { return "" },
+			want: []string{"Checks are running", "All checks have passed"},
+		},
+		{
+			name: "checks created late",
+			runs: func(poll int) string {
+				if poll < 3 {
+					return ""
+				}
+				return `{"name": "build", "status": "completed", "conclusion": "success"}`
+			},
+			want: []string{"All checks have passed"},
+		},
+		{
+			name: "timed out",
+			runs: func(int) string { return build },
+			want: []string{"Checks timed out"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, stop := checksStub(tt.runs)
+			defer stop()
+			sender := &fakeSender{}
+			a := &HangoutsAction{githubClient: client, hangoutsClient: sender, LiveChecks: tt.live}
+			if err := a.WaitForPullRequestChecks(context.Background(), pullRequestEvent(), policy); err != nil {
+				t.Fatal(err)
+			}
+			if len(sender.sent) > 0 {
+				t.Errorf("%d messages sent, want the checks card only", len(sender.sent))
+			}
+			got := cardTitles(sender.upserted["o/r-1/checks/abc"])
+			if !sameStrings(got, tt.want) {
+				t.Errorf("checks card titles = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSendChecksTimedOut(t *testing.T) {
+	checks := checksOf(
+		check("build", "GitHub Actions", StatusInProgress),
+		check("lint", "GitHub Actions", StatusSuccess),
+		check("ci/circleci: e2e", "ci/circleci", StatusInProgress),
+	)
+	for _, grouped := range []bool{false, true} {
+		sender := &fakeSender{}
+		a := &HangoutsAction{hangoutsClient: sender, GroupChecks: grouped}
+		if err := a.sendChecksTimedOut(context.Background(), "o", "r", pullRequestEvent().PullRequest, checks, time.Hour); err != nil {
+			t.Fatal(err)
+		}
+		card := sender.upserted["o/r-1/checks/abc"][0].Cards[0]
+		// The view and author sections come first
+		got := sectionSummary(card.Sections[3:])
+		want := []string{"Checks (0/2 passed, 2 running): build ci/circleci: e2e"}
+		if grouped {
+			want = []string{
+				"GitHub Actions (0/1 passed, 1 running): build",
+				"ci/circleci (0/1 passed, 1 running): ci/circleci: e2e",
+			}
+		}
+		if !sameStrings(got, want) {
+			t.Errorf("grouped %v: pending sections = %q, want %q", grouped, got, want)
+		}
+		if notice := card.Sections[2].Widgets[0].TextParagraph; notice == nil || notice.Text != "Checks not completed within 1h0m0s" {
+			t.Errorf("grouped %v: section before the pending checks = %+v, want the notice", grouped, card.Sections[2])
+		}
+	}
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"log"
+	"strings"
+
+	"github.com/google/go-github/v28/github"
+	"github.com/mirage20/hangouts-action/hangouts"
+)
+
+// Maximum number of commits listed in a push card. The compare link shows the
+// rest.
+const maxPushCommits = 20
+
+type PushFilter func(event *github.PushEvent) bool
+
+func (a *HangoutsAction) NotifyPush(ctx context.Context, event *github.PushEvent, filters ...PushFilter) error {
+	branch, ok := branchFromRef(event.GetRef())
+	if !ok || event.GetDeleted() {
+		// Tags and deleted branches have no commits to show
+		return nil
+	}
+	kind := "push.pushed"
+	status := StatusSuccess
+	switch {
+	case event.GetCreated():
+		kind = "push.created"
+	case event.GetForced():
+		kind = "push.forced"
+		status = StatusFailure
+	}
+	repo := event.Repo.GetName()
+	owner := event.Repo.Owner.GetLogin()
+	key := branchKey(owner, repo, branch)
+	for _, filter := range filters {
+		if !filter(event) {
+			return nil
+		}
+	}
+	sections := []*hangouts.Section{
+		makeViewSection(key, event.GetCompare()),
+		{
+			Widgets: []*hangouts.WidgetMarkup{
+				makeUserWidget("Pushed by", event.GetSender()),
+			},
+		},
+	}
+	if event.GetForced() {
+		sections[1].Widgets = append(sections[1].Widgets, &hangouts.WidgetMarkup{
+			KeyValue: &hangouts.KeyValue{
+				TopLabel: "Force push",
+				Content:  fmt.Sprintf("%s → %s", shortSha(event.GetBefore()), shortSha(event.GetAfter())),
+			},
+		})
+	}
+	if len(event.Commits) > 0 {
+		sections = append(sections, makeCommitsSection(event.Commits, event.GetCompare()))
+	}
+	var files []string
+	for _, c := range event.Commits {
+		files = append(files, c.Added...)
+		files = append(files, c.Removed...)
+		files = append(files, c.Modified...)
+	}
+	subject := &Subject{
+		Event:  "push",
+		Owner:  owner,
+		Repo:   repo,
+		Branch: branch,
+		Author: event.GetSender().GetLogin(),
+		Files:  files,
+	}
+	msg, err := a.newMessage(kind, &MessageData{
+		Repository: pushRepositoryData(event.Repo),
+		Sender:     userData(event.Sender),
+		Push: &PushData{
+			Branch:     branch,
+			Before:     event.GetBefore(),
+			After:      event.GetAfter(),
+			CompareURL: event.GetCompare(),
+			Commits:    len(event.Commits),
+			Created:    event.GetCreated(),
+			Forced:     event.GetForced(),
+			Pusher:     userData(event.Sender),
+		},
+	}, status, sections...)
+	if err != nil {
+		return err
+	}
+	return a.send(ctx, subject, key, msg)
+}
+
+// pushRepositoryData is repositoryData for the repository type of push events
+func pushRepositoryData(repo *github.PushEventRepository) *RepositoryData {
+	return &RepositoryData{
+		Owner:    repo.GetOwner().GetLogin(),
+		Name:     repo.GetName(),
+		FullName: repo.GetFullName(),
+		URL:      repo.GetHTMLURL(),
+	}
+}
+
+// ProtectedBranchFilter only lets through pushes to branches that have branch
+// protection enabled.
+func (a *HangoutsAction) ProtectedBranchFilter(ctx context.Context) PushFilter {
+	return func(event *github.PushEvent) bool {
+		branch, _ := branchFromRef(event.GetRef())
+		b, _, err := a.githubClient.Repositories.GetBranch(ctx, event.Repo.Owner.GetLogin(), event.Repo.GetName(), branch)
+		if err != nil {
+			log.Printf("cannot check protection of branch %s: %v", branch, err)
+			return false
+		}
+		return b.GetProtected()
+	}
+}
+
+func branchFromRef(ref string) (string, bool) {
+	const prefix = "refs/heads/"
+	if !strings.HasPrefix(ref, prefix) {
+		return "", false
+	}
+	return strings.TrimPrefix(ref, prefix), true
+}
+
+func branchKey(owner, repo, branch string) string {
+	return fmt.Sprintf("%s/%s@%s", owner, repo, branch)
+}
+
+func makeCommitsSection(commits []github.PushEventCommit, compareUrl string) *hangouts.Section {
+	var widgets []*hangouts.WidgetMarkup
+	for i, c := range commits {
+		if i == maxPushCommits {
+			widgets = append(widgets, &hangouts.WidgetMarkup{
+				KeyValue: &hangouts.KeyValue{
+					Content: fmt.Sprintf("+%d more commits", len(commits)-maxPushCommits),
+					Button: &hangouts.Button{
+						TextButton: &hangouts.TextButton{
+							Text: "Compare",
+							OnClick: &hangouts.OnClick{
+								OpenLink: &hangouts.OpenLink{
+									Url: compareUrl,
+								},
+							},
+						},
+					},
+				},
+			})
+			break
+		}
+		widgets = append(widgets, &hangouts.WidgetMarkup{
+			KeyValue: &hangouts.KeyValue{
+				TopLabel: fmt.Sprintf("%s · %s", shortSha(c.GetID()), c.GetAuthor().GetName()),
+				Content:  html.EscapeString(firstLine(c.GetMessage())),
+				Button: &hangouts.Button{
+					TextButton: &hangouts.TextButton{
+						Text: "View",
+						OnClick: &hangouts.OnClick{
+							OpenLink: &hangouts.OpenLink{
+								Url: c.GetURL(),
+							},
+						},
+					},
+				},
+			},
+		})
+	}
+	return &hangouts.Section{
+		Header:  "Commits",
+		Widgets: widgets,
+	}
+}
+
+func firstLine(s string) string {
+	if i := strings.IndexByte(s, '\n'); i >= 0 {
+		return strings.TrimSpace(s[:i])
+	}
+	return strings.TrimSpace(s)
+}
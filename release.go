@@ -0,0 +1,172 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/google/go-github/v28/github"
+	"github.com/mirage20/hangouts-action/hangouts"
+)
+
+// Release notes longer than this are cut and linked to the release page
+const maxReleaseNotesLength = 2000
+
+type ReleaseFilter func(event *github.ReleaseEvent) bool
+
+func (a *HangoutsAction) NotifyRelease(ctx context.Context, event *github.ReleaseEvent, filters ...ReleaseFilter) error {
+	if *event.Action != "published" {
+		return nil
+	}
+	release := event.Release
+	kind := "release.published"
+	if release.GetPrerelease() {
+		kind = "release.prereleased"
+	}
+	repo := *event.Repo.Name
+	owner := *event.Repo.Owner.Login
+	key := releaseKey(owner, repo, release.GetTagName())
+	for _, filter := range filters {
+		if !filter(event) {
+			return nil
+		}
+	}
+	sections := []*hangouts.Section{
+		makeViewSection(fmt.Sprintf("%s/%s %s", owner, repo, release.GetTagName()), release.GetHTMLURL()),
+		makeReleaseDetailsSection(release),
+	}
+	if len(strings.TrimSpace(release.GetBody())) > 0 {
+		sections = append(sections, makeReleaseNotesSection(release))
+	}
+	if len(release.Assets) > 0 {
+		sections = append(sections, makeAssetsSection(release.Assets))
+	}
+	subject := &Subject{
+		Event:  "release",
+		Owner:  owner,
+		Repo:   repo,
+		Branch: release.GetTargetCommitish(),
+		Author: release.GetAuthor().GetLogin(),
+	}
+	msg, err := a.newMessage(kind, &MessageData{
+		Repository: repositoryData(event.Repo),
+		Sender:     userData(event.Sender),
+		Release: &ReleaseData{
+			Tag:         release.GetTagName(),
+			Name:        release.GetName(),
+			Body:        release.GetBody(),
+			URL:         release.GetHTMLURL(),
+			Prerelease:  release.GetPrerelease(),
+			Author:      userData(release.GetAuthor()),
+			PublishedAt: release.GetPublishedAt().Time,
+		},
+	}, StatusSuccess, sections...)
+	if err != nil {
+		return err
+	}
+	return a.send(ctx, subject, key, msg)
+}
+
+func releaseKey(owner, repo, tag string) string {
+	return fmt.Sprintf("%s/%s-release-%s", owner, repo, tag)
+}
+
+func makeReleaseDetailsSection(release *github.RepositoryRelease) *hangouts.Section {
+	widgets := []*hangouts.WidgetMarkup{
+		{
+			KeyValue: &hangouts.KeyValue{
+				TopLabel: "Tag",
+				Content:  release.GetTagName(),
+			},
+		},
+	}
+	var flags []string
+	if release.GetPrerelease() {
+		flags = append(flags, "Pre-release")
+	}
+	if release.GetDraft() {
+		flags = append(flags, "Draft")
+	}
+	if len(flags) > 0 {
+		widgets = append(widgets, &hangouts.WidgetMarkup{
+			KeyValue: &hangouts.KeyValue{
+				TopLabel: "Flags",
+				Content:  strings.Join(flags, ", "),
+			},
+		})
+	}
+	widgets = append(widgets, makeUserWidget("Author", release.GetAuthor()))
+	return &hangouts.Section{
+		Widgets: widgets,
+	}
+}
+
+func makeReleaseNotesSection(release *github.RepositoryRelease) *hangouts.Section {
+	notes, truncated := truncateMarkdown(release.GetBody(), maxReleaseNotesLength)
+	widgets := []*hangouts.WidgetMarkup{
+		{
+			TextParagraph: &hangouts.TextParagraph{
+				Text: markdownToChat(notes),
+			},
+		},
+	}
+	if truncated {
+		widgets = append(widgets, &hangouts.WidgetMarkup{
+			Buttons: []*hangouts.Button{
+				{
+					TextButton: &hangouts.TextButton{
+						Text: "Read more",
+						OnClick: &hangouts.OnClick{
+							OpenLink: &hangouts.OpenLink{
+								Url: release.GetHTMLURL(),
+							},
+						},
+					},
+				},
+			},
+		})
+	}
+	return &hangouts.Section{
+		Header:  "Release notes",
+		Widgets: widgets,
+	}
+}
+
+func makeAssetsSection(assets []github.ReleaseAsset) *hangouts.Section {
+	var widgets []*hangouts.WidgetMarkup
+	for _, asset := range assets {
+		widgets = append(widgets, &hangouts.WidgetMarkup{
+			KeyValue: &hangouts.KeyValue{
+				Content:     asset.GetName(),
+				BottomLabel: formatSize(asset.GetSize()),
+				Button: &hangouts.Button{
+					TextButton: &hangouts.TextButton{
+						Text: "Download",
+						OnClick: &hangouts.OnClick{
+							OpenLink: &hangouts.OpenLink{
+								Url: asset.GetBrowserDownloadURL(),
+							},
+						},
+					},
+				},
+			},
+		})
+	}
+	return &hangouts.Section{
+		Header:  "Assets",
+		Widgets: widgets,
+	}
+}
+
+func formatSize(bytes int) string {
+	const unit = 1024
+	if bytes < unit {
+		return fmt.Sprintf("%d B", bytes)
+	}
+	div, exp := unit, 0
+	for n := bytes / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(bytes)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"strings"
+
+	"github.com/google/go-github/v28/github"
+	"github.com/mirage20/hangouts-action/hangouts"
+)
+
+// Number of characters of a review or comment body shown in the card
+const excerptLength = 300
+
+type PullRequestReviewFilter func(event *github.PullRequestReviewEvent) bool
+type PullRequestReviewCommentFilter func(event *github.PullRequestReviewCommentEvent) bool
+
+func (a *HangoutsAction) NotifyPullRequestReview(ctx context.Context, event *github.PullRequestReviewEvent, filters ...PullRequestReviewFilter) error {
+	review := event.Review
+	var kind string
+	var status Status
+	switch *event.Action {
+	case "submitted":
+		switch strings.ToLower(review.GetState()) {
+		case "approved":
+			kind = "pull_request_review.approved"
+			status = StatusSuccess
+		case "changes_requested":
+			kind = "pull_request_review.changes_requested"
+			status = StatusFailure
+		default:
+			kind = "pull_request_review.commented"
+			status = StatusInProgress
+		}
+	case "dismissed":
+		kind = "pull_request_review.dismissed"
+		status = StatusInProgress
+	default:
+		return nil
+	}
+	repo := *event.Repo.Name
+	owner := *event.Repo.Owner.Login
+	pr := event.PullRequest
+	prKey := pullRequestKey(owner, repo, *pr.Number)
+	for _, filter := range filters {
+		if !filter(event) {
+			return nil
+		}
+	}
+	sections := []*hangouts.Section{
+		makeViewSection(prKey, *pr.HTMLURL),
+		{
+			Widgets: []*hangouts.WidgetMarkup{
+				makeUserWidget("Reviewer", review.GetUser()),
+			},
+		},
+	}
+	if len(review.GetBody()) > 0 {
+		sections = append(sections, makeExcerptSection(review.GetBody(), "View review", review.GetHTMLURL()))
+	}
+	msg, err := a.newMessage(kind, &MessageData{
+		Repository:  repositoryData(event.Repo),
+		Sender:      userData(event.Sender),
+		PullRequest: pullRequestData(pr),
+		Review: &ReviewData{
+			State:    strings.TrimPrefix(kind, "pull_request_review."),
+			Body:     review.GetBody(),
+			URL:      review.GetHTMLURL(),
+			Reviewer: userData(review.GetUser()),
+		},
+	}, status, sections...)
+	if err != nil {
+		return err
+	}
+	return a.send(ctx, pullRequestSubject("pull_request_review", owner, repo, pr), prKey, msg)
+}
+
+func (a *HangoutsAction) NotifyPullRequestReviewComment(ctx context.Context, event *github.PullRequestReviewCommentEvent, filters ...PullRequestReviewCommentFilter) error {
+	if *event.Action != "created" {
+		return nil
+	}
+	comment := event.Comment
+	repo := *event.Repo.Name
+	owner := *event.Repo.Owner.Login
+	pr := event.PullRequest
+	prKey := pullRequestKey(owner, repo, *pr.Number)
+	for _, filter := range filters {
+		if !filter(event) {
+			return nil
+		}
+	}
+	msg, err := a.newMessage("pull_request_review_comment.created", &MessageData{
+		Repository:  repositoryData(event.Repo),
+		Sender:      userData(event.Sender),
+		PullRequest: pullRequestData(pr),
+		Comment: &CommentData{
+			Body:   comment.GetBody(),
+			Path:   comment.GetPath(),
+			URL:    comment.GetHTMLURL(),
+			Author: userData(comment.GetUser()),
+		},
+	}, StatusInProgress,
+		makeViewSection(prKey, *pr.HTMLURL),
+		&hangouts.Section{
+			Widgets: []*hangouts.WidgetMarkup{
+				makeUserWidget("Commented by", comment.GetUser()),
+				{
+					KeyValue: &hangouts.KeyValue{
+						TopLabel: "File",
+						Content:  comment.GetPath(),
+					},
+				},
+			},
+		},
+		makeExcerptSection(comment.GetBody(), "View comment", comment.GetHTMLURL()),
+	)
+	if err != nil {
+		return err
+	}
+	return a.send(ctx, pullRequestSubject("pull_request_review_comment", owner, repo, pr), prKey, msg)
+}
+
+func makeReviewRequestSection(event *github.PullRequestEvent) *hangouts.Section {
+	var reviewer *hangouts.WidgetMarkup
+	if event.RequestedTeam != nil {
+		reviewer = &hangouts.WidgetMarkup{
+			KeyValue: &hangouts.KeyValue{
+				TopLabel: "Team",
+				Content:  event.RequestedTeam.GetName(),
+			},
+		}
+	} else {
+		reviewer = makeUserWidget("Reviewer", event.GetRequestedReviewer())
+	}
+	senderLabel := "Requested by"
+	if event.GetAction() == "review_request_removed" {
+		senderLabel = "Removed by"
+	}
+	return &hangouts.Section{
+		Widgets: []*hangouts.WidgetMarkup{
+			reviewer,
+			makeUserWidget(senderLabel, event.GetSender()),
+		},
+	}
+}
+
+func makeExcerptSection(body, buttonText, url string) *hangouts.Section {
+	return &hangouts.Section{
+		Widgets: []*hangouts.WidgetMarkup{
+			{
+				TextParagraph: &hangouts.TextParagraph{
+					Text: strings.Replace(html.EscapeString(excerpt(body, excerptLength)), "\n", "<br>", -1),
+				},
+			},
+			{
+				Buttons: []*hangouts.Button{
+					{
+						TextButton: &hangouts.TextButton{
+							Text: buttonText,
+							OnClick: &hangouts.OnClick{
+								OpenLink: &hangouts.OpenLink{
+									Url: url,
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+// excerpt shortens s to at most n characters, marking the cut with an ellipsis
+func excerpt(s string, n int) string {
+	s = strings.TrimSpace(s)
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	return fmt.Sprintf("%s…", strings.TrimSpace(string(r[:n])))
+}
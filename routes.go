@@ -0,0 +1,335 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/google/go-github/v28/github"
+	"github.com/mirage20/hangouts-action/hangouts"
+)
+
+// Thread key strategies of a route
+const (
+	// ThreadKeyDefault threads messages the way the notifier does, e.g. one
+	// thread per pull request
+	ThreadKeyDefault = "default"
+	// ThreadKeyNone starts a new thread for each message
+	ThreadKeyNone = "none"
+	// ThreadKeyRepository uses a single thread per repository
+	ThreadKeyRepository = "repository"
+	// ThreadKeyBranch uses a single thread per branch
+	ThreadKeyBranch = "branch"
+)
+
+// Subject describes what a notification is about. Routes are matched against
+// it.
+type Subject struct {
+	// Event is the GitHub event name, or "checks" for checks cards
+	Event string
+	Owner string
+	Repo  string
+	// Branch is the base branch of a pull request or the pushed branch
+	Branch string
+	Labels []string
+	// Author is the login of the author of the pull request, issue, release
+	// or push
+	Author string
+	// PullRequest is the number of the pull request, zero when the
+	// notification is not about a pull request
+	PullRequest int
+	// Files changed, when known from the event payload
+	Files []string
+}
+
+// Route sends the notifications matching its filters to a webhook or a space
+type Route struct {
+	Name    string `json:"name" yaml:"name"`
+	Webhook string `json:"webhook" yaml:"webhook"`
+	// WebhookEnv is the env var holding the webhook url, so that the url is
+	// not committed with the configuration file
+	WebhookEnv string       `json:"webhook_env" yaml:"webhook_env"`
+	Space      string       `json:"space" yaml:"space"`
+	ThreadKey  string       `json:"thread_key" yaml:"thread_key"`
+	Filters    RouteFilters `json:"filters" yaml:"filters"`
+
+	sender hangouts.Sender
+	paths  *PathMatcher
+}
+
+// RouteFilters select the notifications of a route. A notification has to
+// match every filter that is set, and matches a filter when it matches any of
+// its values. Repositories and branches are glob patterns.
+type RouteFilters struct {
+	Events       []string `json:"events" yaml:"events"`
+	Repositories []string `json:"repositories" yaml:"repositories"`
+	Branches     []string `json:"branches" yaml:"branches"`
+	Labels       []string `json:"labels" yaml:"labels"`
+	// Paths and PathsIgnore are CODEOWNERS style patterns, see PathMatcher
+	Paths       []string `json:"paths" yaml:"paths"`
+	PathsIgnore []string `json:"paths_ignore" yaml:"paths_ignore"`
+	Authors     []string `json:"authors" yaml:"authors"`
+	// Teams are given as org/team-slug
+	Teams []string `json:"teams" yaml:"teams"`
+}
+
+// validate checks the route and resolves its webhook url and patterns
+func (r *Route) validate() error {
+	set := 0
+	for _, v := range []string{r.Webhook, r.WebhookEnv, r.Space} {
+		if len(v) > 0 {
+			set++
+		}
+	}
+	if set != 1 {
+		return fmt.Errorf("route %s: exactly one of webhook, webhook_env and space must be set", r.Name)
+	}
+	r.Space = spaceName(r.Space)
+	if len(r.WebhookEnv) > 0 {
+		r.Webhook = os.Getenv(r.WebhookEnv)
+		if len(r.Webhook) == 0 {
+			return fmt.Errorf("route %s: environment variable %s is not set", r.Name, r.WebhookEnv)
+		}
+	}
+	if len(r.Webhook) > 0 {
+		if _, err := validateWebhookUrl(r.Webhook, fmt.Sprintf("route %s", r.Name)); err != nil {
+			return err
+		}
+	}
+	switch r.ThreadKey {
+	case "", ThreadKeyDefault, ThreadKeyNone, ThreadKeyRepository, ThreadKeyBranch:
+	default:
+		return fmt.Errorf("route %s: unknown thread_key %q", r.Name, r.ThreadKey)
+	}
+	for _, team := range r.Filters.Teams {
+		if len(strings.Split(team, "/")) != 2 {
+			return fmt.Errorf("route %s: team %q is not in the form org/team-slug", r.Name, team)
+		}
+	}
+	if len(r.Filters.Paths) > 0 || len(r.Filters.PathsIgnore) > 0 {
+		m, err := NewPathMatcher(r.Filters.Paths, r.Filters.PathsIgnore)
+		if err != nil {
+			return fmt.Errorf("route %s: %v", r.Name, err)
+		}
+		r.paths = m
+	}
+	for _, patterns := range [][]string{r.Filters.Repositories, r.Filters.Branches} {
+		for _, p := range patterns {
+			if _, err := path.Match(p, ""); err != nil {
+				return fmt.Errorf("route %s: invalid pattern %q", r.Name, p)
+			}
+		}
+	}
+	return nil
+}
+
+func (r *Route) threadKey(s *Subject, key string) string {
+	switch r.ThreadKey {
+	case ThreadKeyNone:
+		return ""
+	case ThreadKeyRepository:
+		return fmt.Sprintf("%s/%s", s.Owner, s.Repo)
+	case ThreadKeyBranch:
+		return branchKey(s.Owner, s.Repo, s.Branch)
+	default:
+		return key
+	}
+}
+
+// send posts the message to every destination the subject is routed to. A
+// message exceeding the limits of Chat is split over several messages.
+func (a *HangoutsAction) send(ctx context.Context, s *Subject, threadKey string, msg *hangouts.Message) error {
+	msgs := splitMessage(msg, hangouts.DefaultLimits)
+	sendAll := func(sender hangouts.Sender, threadKey string) error {
+		for _, m := range msgs {
+			if _, err := sender.SendContext(ctx, threadKey, m); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if len(a.routes) == 0 {
+		return sendAll(a.hangoutsClient, threadKey)
+	}
+	return a.eachRoute(ctx, s, func(r *Route) error {
+		return sendAll(r.sender, r.threadKey(s, threadKey))
+	})
+}
+
+// upsert is like send, but updates the messages posted earlier with the same
+// id in each destination. When the message needs fewer messages than before,
+// the extra ones are emptied. The number of messages is only recorded once
+// every destination got them.
+func (a *HangoutsAction) upsert(ctx context.Context, s *Subject, id, threadKey string, msg *hangouts.Message, updateMask ...string) error {
+	msgs := splitMessage(msg, hangouts.DefaultLimits)
+	n := len(msgs)
+	for len(msgs) < a.pages[id] {
+		msgs = append(msgs, emptyPage(msg))
+	}
+	upsertAll := func(sender hangouts.Sender, threadKey string) error {
+		for i, m := range msgs {
+			pageId := id
+			if i > 0 {
+				pageId = fmt.Sprintf("%s#%d", id, i+1)
+			}
+			if _, err := sender.UpsertContext(ctx, pageId, threadKey, m, updateMask...); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	var err error
+	if len(a.routes) == 0 {
+		err = upsertAll(a.hangoutsClient, threadKey)
+	} else {
+		err = a.eachRoute(ctx, s, func(r *Route) error {
+			return upsertAll(r.sender, r.threadKey(s, threadKey))
+		})
+	}
+	if err != nil {
+		// The pages posted earlier are emptied by the next successful upsert
+		return err
+	}
+	if a.pages == nil {
+		a.pages = make(map[string]int)
+	}
+	a.pages[id] = n
+	return nil
+}
+
+// eachRoute calls fn for every route matching the subject. A failing route
+// does not keep the message from the other routes.
+func (a *HangoutsAction) eachRoute(ctx context.Context, s *Subject, fn func(r *Route) error) error {
+	var errs []string
+	matched := false
+	for _, r := range a.routes {
+		ok, err := a.matchRoute(ctx, r, s)
+		if err == nil && ok {
+			matched = true
+			err = fn(r)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("route %s: %v", r.Name, err))
+		}
+	}
+	if !matched && len(errs) == 0 {
+		log.Printf("no route matches %s event of %s/%s, nothing to notify", s.Event, s.Owner, s.Repo)
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("%s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+func (a *HangoutsAction) matchRoute(ctx context.Context, r *Route, s *Subject) (bool, error) {
+	f := r.Filters
+	if len(f.Events) > 0 && !containsString(f.Events, s.Event) {
+		return false, nil
+	}
+	if len(f.Repositories) > 0 && !matchAnyPattern(f.Repositories, s.Owner+"/"+s.Repo) {
+		return false, nil
+	}
+	if len(f.Branches) > 0 && !matchAnyPattern(f.Branches, s.Branch) {
+		return false, nil
+	}
+	if len(f.Labels) > 0 && !containsAnyString(f.Labels, s.Labels) {
+		return false, nil
+	}
+	if len(f.Authors) > 0 && !containsString(f.Authors, s.Author) {
+		return false, nil
+	}
+	if len(f.Teams) > 0 {
+		ok, err := a.isMemberOfAnyTeam(ctx, f.Teams, s.Author)
+		if err != nil || !ok {
+			return false, err
+		}
+	}
+	if r.paths != nil {
+		files := s.Files
+		if s.PullRequest > 0 {
+			var err error
+			files, err = a.ChangedFiles(ctx, s.Owner, s.Repo, s.PullRequest)
+			if err != nil {
+				return false, err
+			}
+		}
+		return r.paths.MatchAny(files), nil
+	}
+	return true, nil
+}
+
+// isMemberOfAnyTeam reports whether the user is an active member of any of
+// the org/team-slug teams
+func (a *HangoutsAction) isMemberOfAnyTeam(ctx context.Context, teams []string, user string) (bool, error) {
+	for _, t := range teams {
+		parts := strings.SplitN(t, "/", 2)
+		m, err := a.getTeamMembershipBySlug(ctx, parts[0], parts[1], user)
+		if err != nil {
+			return false, err
+		}
+		if m.GetState() == "active" {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// getTeamMembershipBySlug returns the membership of the user in the team, or
+// an empty membership when the user is not a member. The client has no
+// method for this endpoint, which replaces the deprecated team id endpoints.
+func (a *HangoutsAction) getTeamMembershipBySlug(ctx context.Context, org, slug, user string) (*github.Membership, error) {
+	u := fmt.Sprintf("orgs/%s/teams/%s/memberships/%s", url.PathEscape(org), url.PathEscape(slug), url.PathEscape(user))
+	req, err := a.githubClient.NewRequest("GET", u, nil)
+	if err != nil {
+		return nil, err
+	}
+	m := &github.Membership{}
+	resp, err := a.githubClient.Do(ctx, req, m)
+	if resp != nil && resp.StatusCode == http.StatusNotFound {
+		return &github.Membership{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func matchAnyPattern(patterns []string, name string) bool {
+	for _, p := range patterns {
+		if ok, _ := path.Match(p, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+func containsAnyString(list []string, values []string) bool {
+	for _, v := range values {
+		if containsString(list, v) {
+			return true
+		}
+	}
+	return false
+}
+
+func labelNames(labels []*github.Label) []string {
+	var names []string
+	for _, l := range labels {
+		names = append(names, l.GetName())
+	}
+	return names
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/mirage20/hangouts-action/hangouts"
+)
+
+func TestInputsRoutes(t *testing.T) {
+	const webhook = "https://chat.googleapis.com/v1/spaces/x/messages?key=k&token=secret"
+	tests := []struct {
+		name   string
+		routes string
+		want   int
+		errs   []string
+	}{
+		{
+			name:   "valid",
+			routes: `[{"name": "docs", "webhook": "` + webhook + `", "filters": {"paths": ["docs/"], "paths_ignore": ["*.png"]}}, {"space": "AAA"}]`,
+			want:   2,
+		},
+		{
+			name:   "misspelled filter",
+			routes: `[{"name": "docs", "webhook": "` + webhook + `", "filters": {"path": ["docs/"]}}]`,
+			errs:   []string{`input routes is not a valid JSON array of routes: json: unknown field "path"`},
+		},
+		{
+			name:   "former name of paths_ignore",
+			routes: `[{"name": "docs", "webhook": "` + webhook + `", "filters": {"exclude_paths": ["*.png"]}}]`,
+			errs:   []string{`input routes is not a valid JSON array of routes: json: unknown field "exclude_paths"`},
+		},
+		{
+			name:   "data after the array",
+			routes: `[{"space": "AAA"}] {"space": "BBB"}`,
+			errs:   []string{"input routes is not a valid JSON array of routes: unexpected data after the array"},
+		},
+		{
+			name:   "invalid route",
+			routes: `[{"webhook": "` + webhook + `", "space": "AAA"}]`,
+			want:   1,
+			errs:   []string{"input routes: route #1: exactly one of webhook, webhook_env and space must be set"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			in := &inputs{lookup: func(key string) (string, bool) {
+				if key == "INPUT_ROUTES" {
+					return tt.routes, true
+				}
+				return "", false
+			}}
+			routes := in.Routes("routes")
+			if len(routes) != tt.want {
+				t.Errorf("%d routes, want %d", len(routes), tt.want)
+			}
+			if !sameStrings(in.errs, tt.errs) {
+				t.Errorf("errors = %q, want %q", in.errs, tt.errs)
+			}
+			for _, e := range in.errs {
+				if strings.Contains(e, "secret") {
+					t.Errorf("error %q shows the webhook url", e)
+				}
+			}
+		})
+	}
+}
+
+// newRoute validates a route of the space with the filters
+func newRoute(t *testing.T, filters RouteFilters) *Route {
+	r := &Route{Name: "test", Space: "spaces/x", Filters: filters}
+	if err := r.validate(); err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func TestMatchRoute(t *testing.T) {
+	client, stop := newGitHubStub(map[string]http.HandlerFunc{
+		"/orgs/o/teams/core/memberships/octocat": func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, `{"state": "active", "role": "member"}`)
+		},
+		"/orgs/o/teams/core/memberships/invited": func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, `{"state": "pending", "role": "member"}`)
+		},
+		"/orgs/o/teams/core/memberships/": func(w http.ResponseWriter, r *http.Request) {
+			http.NotFound(w, r)
+		},
+	})
+	defer stop()
+	a := &HangoutsAction{githubClient: client}
+	subject := &Subject{
+		Event:  "pull_request",
+		Owner:  "o",
+		Repo:   "r",
+		Branch: "release-1.0",
+		Labels: []string{"bug", "docs"},
+		Author: "octocat",
+		Files:  []string{"docs/index.md"},
+	}
+	tests := []struct {
+		name    string
+		filters RouteFilters
+		subject func(s Subject) Subject
+		want    bool
+	}{
+		{"no filters", RouteFilters{}, nil, true},
+		{"event", RouteFilters{Events: []string{"push", "pull_request"}}, nil, true},
+		{"other event", RouteFilters{Events: []string{"push"}}, nil, false},
+		{"repository", RouteFilters{Repositories: []string{"o/*"}}, nil, true},
+		{"other repository", RouteFilters{Repositories: []string{"other/*"}}, nil, false},
+		{"branch", RouteFilters{Branches: []string{"master", "release-*"}}, nil, true},
+		{"other branch", RouteFilters{Branches: []string{"master"}}, nil, false},
+		{"label", RouteFilters{Labels: []string{"docs"}}, nil, true},
+		{"other label", RouteFilters{Labels: []string{"feature"}}, nil, false},
+		{"author", RouteFilters{Authors: []string{"octocat"}}, nil, true},
+		{"other author", RouteFilters{Authors: []string{"hubot"}}, nil, false},
+		{"path", RouteFilters{Paths: []string{"docs/"}}, nil, true},
+		{"ignored path", RouteFilters{PathsIgnore: []string{"*.md"}}, nil, false},
+		{"team member", RouteFilters{Teams: []string{"o/core"}}, nil, true},
+		{"pending team member", RouteFilters{Teams: []string{"o/core"}}, func(s Subject) Subject { s.Author = "invited"; return s }, false},
+		{"not a team member", RouteFilters{Teams: []string{"o/core"}}, func(s Subject) Subject { s.Author = "hubot"; return s }, false},
+		{"every filter", RouteFilters{Events: []string{"pull_request"}, Branches: []string{"release-*"}, Authors: []string{"hubot"}}, nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := *subject
+			if tt.subject != nil {
+				s = tt.subject(s)
+			}
+			got, err := a.matchRoute(context.Background(), newRoute(t, tt.filters), &s)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("matchRoute() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRouteThreadKey(t *testing.T) {
+	s := &Subject{Owner: "o", Repo: "r", Branch: "master"}
+	tests := map[string]string{
+		"":                  "o/r-1",
+		ThreadKeyDefault:    "o/r-1",
+		ThreadKeyNone:       "",
+		ThreadKeyRepository: "o/r",
+		ThreadKeyBranch:     "o/r@master",
+	}
+	for strategy, want := range tests {
+		r := &Route{ThreadKey: strategy}
+		if got := r.threadKey(s, "o/r-1"); got != want {
+			t.Errorf("thread key %q = %q, want %q", strategy, got, want)
+		}
+	}
+}
+
+func TestEachRoute(t *testing.T) {
+	failing := &fakeSender{err: errors.New("unauthorized")}
+	docs, all := &fakeSender{}, &fakeSender{}
+	routes := []*Route{
+		{Name: "failing", sender: failing},
+		{Name: "docs", sender: docs, Filters: RouteFilters{Labels: []string{"docs"}}},
+		{Name: "releases", sender: &fakeSender{}, Filters: RouteFilters{Events: []string{"release"}}},
+		{Name: "all", sender: all},
+	}
+	a := &HangoutsAction{routes: routes}
+	s := &Subject{Event: "pull_request", Owner: "o", Repo: "r", Labels: []string{"docs"}}
+	err := a.send(context.Background(), s, "o/r-1", &hangouts.Message{Text: "hi"})
+	if err == nil || err.Error() != "route failing: unauthorized" {
+		t.Errorf("err = %v, want the error of the failing route only", err)
+	}
+	if len(docs.sent) != 1 || len(all.sent) != 1 {
+		t.Errorf("%d and %d messages sent, want the message sent to the other matching routes", len(docs.sent), len(all.sent))
+	}
+	if n := len(routes[2].sender.(*fakeSender).sent); n > 0 {
+		t.Errorf("%d messages sent to a route that does not match", n)
+	}
+
+	a.routes = routes[2:3]
+	if err := a.send(context.Background(), s, "o/r-1", &hangouts.Message{Text: "hi"}); err != nil {
+		t.Errorf("send() = %v, want nil when no route matches", err)
+	}
+}
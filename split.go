@@ -0,0 +1,99 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/mirage20/hangouts-action/hangouts"
+)
+
+// Room left in a message for what the estimate of its size misses
+const splitMarginBytes = 512
+
+// splitMessage spreads the widgets of a message that exceeds the limits of
+// Chat over more cards, and the cards over more messages. Sections cut in two
+// continue in the next card under the same header. The text stays with the
+// first message.
+func splitMessage(msg *hangouts.Message, l hangouts.Limits) []*hangouts.Message {
+	budget := l.MaxMessageBytes - hangouts.JSONSize(&hangouts.Message{Text: msg.Text}) - splitMarginBytes
+	var cards []*hangouts.Card
+	for _, c := range msg.Cards {
+		cards = append(cards, splitCard(c, l.MaxWidgets, budget)...)
+	}
+	var msgs []*hangouts.Message
+	cur := &hangouts.Message{Text: msg.Text}
+	size := 0
+	for _, c := range cards {
+		n := hangouts.JSONSize(c)
+		if len(cur.Cards) > 0 && (len(cur.Cards) >= l.MaxCards || size+n > budget) {
+			msgs = append(msgs, cur)
+			cur, size = &hangouts.Message{}, 0
+		}
+		cur.Cards = append(cur.Cards, c)
+		size += n
+	}
+	return append(msgs, cur)
+}
+
+func splitCard(c *hangouts.Card, maxWidgets, budget int) []*hangouts.Card {
+	if c == nil || (c.WidgetCount() <= maxWidgets && hangouts.JSONSize(c) <= budget) {
+		return []*hangouts.Card{c}
+	}
+	newCard := func(continued bool) *hangouts.Card {
+		card := &hangouts.Card{Name: c.Name, CardActions: c.CardActions}
+		if c.Header != nil {
+			h := *c.Header
+			if continued {
+				h.Subtitle = fmt.Sprintf("%s (continued)", h.Subtitle)
+			}
+			card.Header = &h
+		}
+		return card
+	}
+	card := newCard(false)
+	cards := []*hangouts.Card{card}
+	widgets, size := 0, hangouts.JSONSize(card)
+	for _, s := range c.Sections {
+		if s == nil {
+			continue
+		}
+		var section *hangouts.Section
+		for _, w := range s.Widgets {
+			n := hangouts.JSONSize(w) + 1
+			if widgets > 0 && (widgets+1 > maxWidgets || size+n > budget) {
+				card = newCard(true)
+				cards = append(cards, card)
+				widgets, size, section = 0, hangouts.JSONSize(card), nil
+			}
+			if section == nil {
+				section = &hangouts.Section{Header: s.Header}
+				card.Sections = append(card.Sections, section)
+				size += hangouts.JSONSize(section)
+			}
+			section.Widgets = append(section.Widgets, w)
+			widgets++
+			size += n
+		}
+	}
+	return cards
+}
+
+// emptyPage replaces a message of a split message that is no longer needed,
+// as messages cannot be deleted through webhooks.
+func emptyPage(msg *hangouts.Message) *hangouts.Message {
+	card := &hangouts.Card{
+		Sections: []*hangouts.Section{
+			{
+				Widgets: []*hangouts.WidgetMarkup{
+					{
+						TextParagraph: &hangouts.TextParagraph{Text: "<i>Nothing more to show</i>"},
+					},
+				},
+			},
+		},
+	}
+	if len(msg.Cards) > 0 && msg.Cards[0] != nil && msg.Cards[0].Header != nil {
+		h := *msg.Cards[0].Header
+		card.Header = &h
+	}
+	return &hangouts.Message{Cards: []*hangouts.Card{card}}
+}
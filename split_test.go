@@ -0,0 +1,141 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/mirage20/hangouts-action/hangouts"
+)
+
+func textWidgets(n, size int) []*hangouts.WidgetMarkup {
+	widgets := make([]*hangouts.WidgetMarkup, n)
+	for i := range widgets {
+		text := fmt.Sprintf("%d %s", i, strings.Repeat("x", size))
+		widgets[i] = &hangouts.WidgetMarkup{TextParagraph: &hangouts.TextParagraph{Text: text}}
+	}
+	return widgets
+}
+
+func TestSplitMessage(t *testing.T) {
+	header := &hangouts.CardHeader{Title: "Checks", Subtitle: "Fix"}
+	tests := []struct {
+		name      string
+		msg       *hangouts.Message
+		wantMsgs  int
+		wantCards int
+	}{
+		{
+			"fits",
+			&hangouts.Message{Text: "hi", Cards: []*hangouts.Card{{Header: header, Sections: []*hangouts.Section{{Widgets: textWidgets(10, 10)}}}}},
+			1, 1,
+		},
+		{
+			"too many widgets",
+			&hangouts.Message{Cards: []*hangouts.Card{{Header: header, Sections: []*hangouts.Section{{Widgets: textWidgets(250, 10)}}}}},
+			1, 3,
+		},
+		{
+			"too many bytes",
+			&hangouts.Message{Cards: []*hangouts.Card{{Header: header, Sections: []*hangouts.Section{{Widgets: textWidgets(90, 1000)}}}}},
+			3, 3,
+		},
+		{
+			"nil entries",
+			&hangouts.Message{Cards: []*hangouts.Card{nil, {Sections: []*hangouts.Section{nil, {Widgets: textWidgets(150, 10)}}}}},
+			1, 3,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msgs := splitMessage(tt.msg, hangouts.DefaultLimits)
+			if len(msgs) != tt.wantMsgs {
+				t.Errorf("%d messages, want %d", len(msgs), tt.wantMsgs)
+			}
+			cards, widgets := 0, 0
+			for i, m := range msgs {
+				if n := hangouts.JSONSize(m); n > hangouts.DefaultLimits.MaxMessageBytes {
+					t.Errorf("message %d has %d bytes", i, n)
+				}
+				for _, c := range m.Cards {
+					if n := c.WidgetCount(); n > hangouts.DefaultLimits.MaxWidgets {
+						t.Errorf("message %d has a card with %d widgets", i, n)
+					}
+					widgets += c.WidgetCount()
+				}
+				cards += len(m.Cards)
+			}
+			if cards != tt.wantCards {
+				t.Errorf("%d cards, want %d", cards, tt.wantCards)
+			}
+			want := 0
+			for _, c := range tt.msg.Cards {
+				want += c.WidgetCount()
+			}
+			if widgets != want {
+				t.Errorf("%d widgets after splitting, want %d", widgets, want)
+			}
+			if msgs[0].Text != tt.msg.Text {
+				t.Errorf("text = %q, want it on the first message", msgs[0].Text)
+			}
+			for _, m := range msgs[1:] {
+				if len(m.Text) > 0 {
+					t.Errorf("text repeated on a later message")
+				}
+			}
+		})
+	}
+}
+
+func TestSplitCard(t *testing.T) {
+	card := &hangouts.Card{
+		Header: &hangouts.CardHeader{Title: "Checks", Subtitle: "Fix"},
+		Sections: []*hangouts.Section{
+			{Header: "Failed", Widgets: textWidgets(3, 10)},
+			{Header: "Passed", Widgets: textWidgets(5, 10)},
+		},
+	}
+	cards := splitCard(card, 4, 32000)
+	want := []struct {
+		subtitle string
+		sections []string
+		widgets  int
+	}{
+		{"Fix", []string{"Failed", "Passed"}, 4},
+		{"Fix (continued)", []string{"Passed"}, 4},
+	}
+	if len(cards) != len(want) {
+		t.Fatalf("%d cards, want %d", len(cards), len(want))
+	}
+	for i, w := range want {
+		c := cards[i]
+		if c.Header.Subtitle != w.subtitle {
+			t.Errorf("card %d subtitle = %q, want %q", i, c.Header.Subtitle, w.subtitle)
+		}
+		var headers []string
+		for _, s := range c.Sections {
+			headers = append(headers, s.Header)
+		}
+		if strings.Join(headers, ",") != strings.Join(w.sections, ",") {
+			t.Errorf("card %d sections = %q, want %q", i, headers, w.sections)
+		}
+		if c.WidgetCount() != w.widgets {
+			t.Errorf("card %d has %d widgets, want %d", i, c.WidgetCount(), w.widgets)
+		}
+	}
+	if card.Header.Subtitle != "Fix" {
+		t.Errorf("splitCard modified the header of the card")
+	}
+}
+
+func TestEmptyPage(t *testing.T) {
+	for _, msg := range []*hangouts.Message{
+		{},
+		{Cards: []*hangouts.Card{nil}},
+		{Cards: []*hangouts.Card{{Header: &hangouts.CardHeader{Title: "Checks"}}}},
+	} {
+		if err := emptyPage(msg).Validate(); err != nil {
+			t.Errorf("emptyPage(%+v) is invalid: %v", msg, err)
+		}
+	}
+}
@@ -0,0 +1,423 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"text/template"
+	"text/template/parse"
+	"time"
+
+	"github.com/google/go-github/v28/github"
+	"github.com/mirage20/hangouts-action/hangouts"
+)
+
+// MessageTemplate holds the text/template templates of the message of a
+// kind. Templates are executed with a *MessageData. Empty fields of a
+// configured template keep the default template of the kind.
+type MessageTemplate struct {
+	// Title and Subtitle of the card header
+	Title    string `yaml:"title"`
+	Subtitle string `yaml:"subtitle"`
+	// Text is posted above the card, and is what notifications show
+	Text string `yaml:"text"`
+	// Message renders the JSON of a whole hangouts.Message, replacing the
+	// default message. The default cards are available as .Cards. Every
+	// value it prints has to go through json, see checkJSONActions.
+	Message string `yaml:"message"`
+}
+
+// Default templates, keyed by the kind of the message. A kind is named
+// <event>.<what happened>, and the keys are the kinds that can be
+// configured.
+var defaultTemplates = map[string]*MessageTemplate{
+	"pull_request.opened":                   {Title: "New pull request is opened", Subtitle: "{{.PullRequest.Title}}"},
+	"pull_request.reopened":                 {Title: "Pull request re-opened", Subtitle: "{{.PullRequest.Title}}"},
+	"pull_request.synchronize":              {Title: "Pull request updated", Subtitle: "{{.PullRequest.Title}}"},
+	"pull_request.merged":                   {Title: "Pull request merged", Subtitle: "{{.PullRequest.Title}}"},
+	"pull_request.closed":                   {Title: "Pull request closed without merging", Subtitle: "{{.PullRequest.Title}}"},
+	"pull_request.review_requested":         {Title: "Review requested", Subtitle: "{{.PullRequest.Title}}"},
+	"pull_request.review_request_removed":   {Title: "Review request removed", Subtitle: "{{.PullRequest.Title}}"},
+	"pull_request_review.approved":          {Title: "Pull request approved", Subtitle: "{{.PullRequest.Title}}"},
+	"pull_request_review.changes_requested": {Title: "Changes requested", Subtitle: "{{.PullRequest.Title}}"},
+	"pull_request_review.commented":         {Title: "Pull request reviewed", Subtitle: "{{.PullRequest.Title}}"},
+	"pull_request_review.dismissed":         {Title: "Review dismissed", Subtitle: "{{.PullRequest.Title}}"},
+	"pull_request_review_comment.created":   {Title: "New review comment", Subtitle: "{{.PullRequest.Title}}"},
+	"checks.success":                        {Title: "All checks have passed", Subtitle: "{{.PullRequest.Title}}"},
+	"checks.failure":                        {Title: "Some checks were not successful", Subtitle: "{{.PullRequest.Title}}"},
+	"checks.in_progress":                    {Title: "Checks are running", Subtitle: "{{.PullRequest.Title}}"},
+	"checks.timed_out":                      {Title: "Checks timed out", Subtitle: "{{.PullRequest.Title}}"},
+	"push.created":                          {Title: "New branch pushed", Subtitle: "{{.Repository.FullName}} {{.Push.Branch}}"},
+	"push.forced":                           {Title: "Branch force pushed", Subtitle: "{{.Repository.FullName}} {{.Push.Branch}}"},
+	"push.pushed":                           {Title: "New commits pushed", Subtitle: "{{.Repository.FullName}} {{.Push.Branch}}"},
+	"release.published":                     {Title: "New release published", Subtitle: "{{or .Release.Name .Release.Tag}}"},
+	"release.prereleased":                   {Title: "New pre-release published", Subtitle: "{{or .Release.Name .Release.Tag}}"},
+	"issues.opened":                         {Title: "New issue is opened", Subtitle: "{{.Issue.Title}}"},
+	"issues.reopened":                       {Title: "Issue re-opened", Subtitle: "{{.Issue.Title}}"},
+	"issues.closed":                         {Title: "Issue closed", Subtitle: "{{.Issue.Title}}"},
+	"issues.labeled":                        {Title: "Issue labelled {{.Label}}", Subtitle: "{{.Issue.Title}}"},
+	"issues.assigned":                       {Title: "Issue assigned to {{.Assignee.Login}}", Subtitle: "{{.Issue.Title}}"},
+}
+
+// MessageData is the data model of the message templates. Fields that do not
+// apply to the kind of the message are nil.
+type MessageData struct {
+	// Kind of the message, e.g. pull_request.opened
+	Kind       string
+	Repository *RepositoryData
+	// Sender is the user who triggered the event
+	Sender      *UserData
+	PullRequest *PullRequestData
+	Review      *ReviewData
+	Comment     *CommentData
+	Issue       *IssueData
+	// Label and Assignee are set for issues.labeled and issues.assigned
+	Label    string
+	Assignee *UserData
+	Push     *PushData
+	Release  *ReleaseData
+	Checks   *ChecksData
+	// Cards are the default cards, only set when executing Message templates
+	Cards []*hangouts.Card
+}
+
+type RepositoryData struct {
+	Owner string
+	Name  string
+	// FullName is owner/name
+	FullName string
+	URL      string
+}
+
+type UserData struct {
+	Login     string
+	URL       string
+	AvatarURL string
+}
+
+type PullRequestData struct {
+	Number int
+	Title  string
+	Body   string
+	URL    string
+	Author *UserData
+	// Head and Base are the branch names
+	Head      string
+	Base      string
+	HeadSHA   string
+	Draft     bool
+	Merged    bool
+	Labels    []string
+	CreatedAt time.Time
+}
+
+type ReviewData struct {
+	// State is approved, changes_requested, commented or dismissed
+	State    string
+	Body     string
+	URL      string
+	Reviewer *UserData
+}
+
+type CommentData struct {
+	Body   string
+	Path   string
+	URL    string
+	Author *UserData
+}
+
+type IssueData struct {
+	Number    int
+	Title     string
+	Body      string
+	URL       string
+	Author    *UserData
+	Labels    []string
+	CreatedAt time.Time
+}
+
+type PushData struct {
+	Branch     string
+	Before     string
+	After      string
+	CompareURL string
+	Commits    int
+	Created    bool
+	Forced     bool
+	Pusher     *UserData
+}
+
+type ReleaseData struct {
+	Tag         string
+	Name        string
+	Body        string
+	URL         string
+	Prerelease  bool
+	Author      *UserData
+	PublishedAt time.Time
+}
+
+type ChecksData struct {
+	// Status is the overall status
+	Status  Status
+	Summary string
+	Total   int
+	Passed  int
+	Failed  int
+	Running int
+	Checks  []Check
+	// Timeout is set for checks.timed_out
+	Timeout time.Duration
+}
+
+// templateFuncs are the helper functions available to the templates.
+// Mentions maps GitHub logins to Chat users, e.g. users/123456789.
+func templateFuncs(mentions map[string]string) template.FuncMap {
+	return template.FuncMap{
+		// truncate 100 .PullRequest.Body
+		"truncate": func(n int, s string) string {
+			return excerpt(s, n)
+		},
+		// relativeTime .PullRequest.CreatedAt, e.g. 3 hours ago
+		"relativeTime": func(t time.Time) string {
+			return relativeTime(t, time.Now())
+		},
+		// mention .PullRequest.Author.Login notifies the Chat user of the
+		// login when it is known
+		"mention": func(login string) string {
+			if user, ok := mentions[login]; ok {
+				return fmt.Sprintf("<%s>", user)
+			}
+			return "@" + login
+		},
+		"join": func(sep string, list []string) string {
+			return strings.Join(list, sep)
+		},
+		"json": func(v interface{}) (string, error) {
+			b, err := json.Marshal(v)
+			return string(b), err
+		},
+	}
+}
+
+func relativeTime(t, now time.Time) string {
+	d := now.Sub(t)
+	suffix := "ago"
+	if d < 0 {
+		d, suffix = -d, "from now"
+	}
+	plural := func(n int, unit string) string {
+		if n == 1 {
+			return fmt.Sprintf("1 %s %s", unit, suffix)
+		}
+		return fmt.Sprintf("%d %ss %s", n, unit, suffix)
+	}
+	switch {
+	case d < time.Minute:
+		return "just now"
+	case d < time.Hour:
+		return plural(int(d/time.Minute), "minute")
+	case d < 24*time.Hour:
+		return plural(int(d/time.Hour), "hour")
+	case d < 30*24*time.Hour:
+		return plural(int(d/(24*time.Hour)), "day")
+	default:
+		return t.Format("Jan 2, 2006")
+	}
+}
+
+// parse checks the syntax of the templates
+func (t *MessageTemplate) parse() map[string]error {
+	errs := make(map[string]error)
+	for field, text := range t.fields() {
+		if _, err := parseTemplate(field, text, templateFuncs(nil)); err != nil {
+			errs[field] = err
+		}
+	}
+	return errs
+}
+
+func parseTemplate(field, text string, funcs template.FuncMap) (*template.Template, error) {
+	tmpl, err := template.New(field).Funcs(funcs).Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	if field == "message" {
+		for _, t := range tmpl.Templates() {
+			if t.Tree == nil {
+				continue
+			}
+			if err := checkJSONActions(t.Tree.Root); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return tmpl, nil
+}
+
+// checkJSONActions makes sure that every value printed by a message template
+// is encoded by json, e.g. {{json .PullRequest.Title}}, so that titles and
+// such containing quotes cannot break the JSON of the message or add fields
+// to it.
+func checkJSONActions(n parse.Node) error {
+	switch n := n.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return nil
+		}
+		for _, c := range n.Nodes {
+			if err := checkJSONActions(c); err != nil {
+				return err
+			}
+		}
+	case *parse.ActionNode:
+		if len(n.Pipe.Decl) > 0 {
+			// Declaring a variable prints nothing
+			return nil
+		}
+		last := n.Pipe.Cmds[len(n.Pipe.Cmds)-1]
+		if id, ok := last.Args[0].(*parse.IdentifierNode); !ok || id.Ident != "json" {
+			return fmt.Errorf("%s prints a value that is not encoded, use json, e.g. {{json .PullRequest.Title}}", n)
+		}
+	case *parse.IfNode:
+		return checkJSONBranch(n.List, n.ElseList)
+	case *parse.RangeNode:
+		return checkJSONBranch(n.List, n.ElseList)
+	case *parse.WithNode:
+		return checkJSONBranch(n.List, n.ElseList)
+	}
+	return nil
+}
+
+func checkJSONBranch(list, elseList *parse.ListNode) error {
+	if err := checkJSONActions(list); err != nil {
+		return err
+	}
+	return checkJSONActions(elseList)
+}
+
+func (t *MessageTemplate) fields() map[string]string {
+	return map[string]string{
+		"title":    t.Title,
+		"subtitle": t.Subtitle,
+		"text":     t.Text,
+		"message":  t.Message,
+	}
+}
+
+// newMessage renders the message of the kind, which is a card with the given
+// status and sections unless a Message template replaces it.
+func (a *HangoutsAction) newMessage(kind string, data *MessageData, status Status, sections ...*hangouts.Section) (*hangouts.Message, error) {
+	t := *defaultTemplates[kind]
+	if c, ok := a.Templates[kind]; ok {
+		if len(c.Title) > 0 {
+			t.Title = c.Title
+		}
+		if len(c.Subtitle) > 0 {
+			t.Subtitle = c.Subtitle
+		}
+		if len(c.Text) > 0 {
+			t.Text = c.Text
+		}
+		if len(c.Message) > 0 {
+			t.Message = c.Message
+		}
+	}
+	data.Kind = kind
+	funcs := templateFuncs(a.Mentions)
+	execute := func(field, text string) (string, error) {
+		tmpl, err := parseTemplate(field, text, funcs)
+		if err != nil {
+			return "", fmt.Errorf("template %s %s: %v", kind, field, err)
+		}
+		var buf bytes.Buffer
+		if err := tmpl.Execute(&buf, data); err != nil {
+			return "", fmt.Errorf("template %s %s: %v", kind, field, err)
+		}
+		return buf.String(), nil
+	}
+	title, err := execute("title", t.Title)
+	if err != nil {
+		return nil, err
+	}
+	subtitle, err := execute("subtitle", t.Subtitle)
+	if err != nil {
+		return nil, err
+	}
+	text, err := execute("text", t.Text)
+	if err != nil {
+		return nil, err
+	}
+	msg := &hangouts.Message{
+		Text: text,
+		Cards: []*hangouts.Card{
+			{
+				Header:   makeCardHeader(title, subtitle, status),
+				Sections: sections,
+			},
+		},
+	}
+	if len(t.Message) == 0 {
+		return msg, nil
+	}
+	data.Cards = msg.Cards
+	out, err := execute("message", t.Message)
+	if err != nil {
+		return nil, err
+	}
+	msg = &hangouts.Message{}
+	if err := json.Unmarshal([]byte(out), msg); err != nil {
+		return nil, fmt.Errorf("template %s message: not a valid message: %v", kind, err)
+	}
+	return msg, nil
+}
+
+func repositoryData(repo *github.Repository) *RepositoryData {
+	return &RepositoryData{
+		Owner:    repo.GetOwner().GetLogin(),
+		Name:     repo.GetName(),
+		FullName: fmt.Sprintf("%s/%s", repo.GetOwner().GetLogin(), repo.GetName()),
+		URL:      repo.GetHTMLURL(),
+	}
+}
+
+func userData(u *github.User) *UserData {
+	if u == nil {
+		return nil
+	}
+	return &UserData{
+		Login:     u.GetLogin(),
+		URL:       u.GetHTMLURL(),
+		AvatarURL: u.GetAvatarURL(),
+	}
+}
+
+func pullRequestData(pr *github.PullRequest) *PullRequestData {
+	return &PullRequestData{
+		Number:    pr.GetNumber(),
+		Title:     pr.GetTitle(),
+		Body:      pr.GetBody(),
+		URL:       pr.GetHTMLURL(),
+		Author:    userData(pr.GetUser()),
+		Head:      pr.GetHead().GetRef(),
+		Base:      pr.GetBase().GetRef(),
+		HeadSHA:   pr.GetHead().GetSHA(),
+		Draft:     pr.GetDraft(),
+		Merged:    pr.GetMerged(),
+		Labels:    labelNames(pr.Labels),
+		CreatedAt: pr.GetCreatedAt(),
+	}
+}
+
+func checksData(checks Checks) *ChecksData {
+	return &ChecksData{
+		Status:  checks.OverallStatus(),
+		Summary: checks.Summary(),
+		Total:   len(checks.ToList()),
+		Passed:  len(checks[StatusSuccess]),
+		Failed:  len(checks[StatusFailure]),
+		Running: len(checks[StatusInProgress]),
+		Checks:  checks.ToList(),
+	}
+}
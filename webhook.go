@@ -0,0 +1,72 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// Sources of the webhook URL in order of precedence. The first one that is
+// set wins, so an explicit action input always overrides an env var that may
+// be inherited from the workflow.
+const (
+	webhookUrlInput   = "INPUT_WEBHOOK_URL"
+	webhookUrlEnv     = "GOOGLE_HANGOUTS_WEBHOOK_URL"
+	webhookUrlFileEnv = "GOOGLE_HANGOUTS_WEBHOOK_URL_FILE"
+
+	serviceAccountKeyInput   = "INPUT_SERVICE_ACCOUNT_KEY"
+	serviceAccountKeyFileEnv = "GOOGLE_APPLICATION_CREDENTIALS"
+)
+
+// resolveWebhookUrl returns the Hangouts Chat webhook URL configured for this
+// run. Errors only name the source of the URL and never include its value.
+func resolveWebhookUrl() (string, error) {
+	for _, key := range []string{webhookUrlInput, webhookUrlEnv} {
+		if v, ok := os.LookupEnv(key); ok && len(strings.TrimSpace(v)) > 0 {
+			return validateWebhookUrl(strings.TrimSpace(v), key)
+		}
+	}
+	if path, ok := os.LookupEnv(webhookUrlFileEnv); ok && len(path) > 0 {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return "", fmt.Errorf("cannot read webhook url from file %s set by %s: %v", path, webhookUrlFileEnv, err)
+		}
+		return validateWebhookUrl(strings.TrimSpace(string(data)), fmt.Sprintf("file %s", path))
+	}
+	return "", fmt.Errorf("webhook url not provided: set the webhook_url input, %s or %s", webhookUrlEnv, webhookUrlFileEnv)
+}
+
+func validateWebhookUrl(v, source string) (string, error) {
+	if len(v) == 0 {
+		return "", fmt.Errorf("webhook url from %s is empty", source)
+	}
+	u, err := url.Parse(v)
+	if err != nil || u.Scheme != "https" || len(u.Host) == 0 {
+		// The parse error echoes the input, so it is deliberately dropped
+		return "", fmt.Errorf("webhook url from %s is not a valid https url", source)
+	}
+	return v, nil
+}
+
+// resolveServiceAccountKey returns the JSON key of the service account used to
+// call the Chat API, given either inline or as the path of the key file.
+// Errors never include the key.
+func resolveServiceAccountKey() ([]byte, error) {
+	if v, ok := os.LookupEnv(serviceAccountKeyInput); ok && len(strings.TrimSpace(v)) > 0 {
+		return []byte(v), nil
+	}
+	if path, ok := os.LookupEnv(serviceAccountKeyFileEnv); ok && len(path) > 0 {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read service account key from file %s set by %s: %v", path, serviceAccountKeyFileEnv, err)
+		}
+		if len(bytes.TrimSpace(data)) == 0 {
+			return nil, fmt.Errorf("service account key file %s set by %s is empty", path, serviceAccountKeyFileEnv)
+		}
+		return data, nil
+	}
+	return nil, fmt.Errorf("service account key not provided: set the service_account_key input or %s when posting to a space", serviceAccountKeyFileEnv)
+}
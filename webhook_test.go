@@ -0,0 +1,106 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestResolveWebhookUrl(t *testing.T) {
+	dir, err := ioutil.TempDir("", "hangouts-action")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	const (
+		inputUrl = "https://chat.googleapis.com/v1/spaces/input/messages?key=k&token=input-secret"
+		envUrl   = "https://chat.googleapis.com/v1/spaces/env/messages?key=k&token=env-secret"
+		fileUrl  = "https://chat.googleapis.com/v1/spaces/file/messages?key=k&token=file-secret"
+	)
+	file := filepath.Join(dir, "webhook")
+	if err := ioutil.WriteFile(file, []byte(fileUrl+"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	invalid := filepath.Join(dir, "invalid")
+	if err := ioutil.WriteFile(invalid, []byte("http://chat.googleapis.com/?token=file-secret"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		env  map[string]string
+		want string
+		err  string
+	}{
+		{
+			name: "input first",
+			env:  map[string]string{webhookUrlInput: inputUrl, webhookUrlEnv: envUrl, webhookUrlFileEnv: file},
+			want: inputUrl,
+		},
+		{
+			name: "env before file",
+			env:  map[string]string{webhookUrlEnv: envUrl, webhookUrlFileEnv: file},
+			want: envUrl,
+		},
+		{
+			name: "blank input",
+			env:  map[string]string{webhookUrlInput: "  ", webhookUrlEnv: envUrl},
+			want: envUrl,
+		},
+		{
+			name: "file",
+			env:  map[string]string{webhookUrlFileEnv: file},
+			want: fileUrl,
+		},
+		{
+			name: "none",
+			err:  "webhook url not provided: set the webhook_url input, GOOGLE_HANGOUTS_WEBHOOK_URL or GOOGLE_HANGOUTS_WEBHOOK_URL_FILE",
+		},
+		{
+			name: "not https",
+			env:  map[string]string{webhookUrlInput: "http://chat.googleapis.com/v1/spaces/x/messages?token=input-secret"},
+			err:  "webhook url from INPUT_WEBHOOK_URL is not a valid https url",
+		},
+		{
+			name: "unparsable",
+			env:  map[string]string{webhookUrlEnv: "https://chat.googleapis.com/%zz?token=env-secret"},
+			err:  "webhook url from GOOGLE_HANGOUTS_WEBHOOK_URL is not a valid https url",
+		},
+		{
+			name: "invalid file",
+			env:  map[string]string{webhookUrlFileEnv: invalid},
+			err:  "webhook url from file " + invalid + " is not a valid https url",
+		},
+		{
+			name: "missing file",
+			env:  map[string]string{webhookUrlFileEnv: filepath.Join(dir, "missing")},
+			err:  "cannot read webhook url from file " + filepath.Join(dir, "missing"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			restore := setenv(t, tt.env)
+			defer restore()
+			for _, key := range []string{webhookUrlEnv, webhookUrlFileEnv} {
+				if _, ok := tt.env[key]; !ok {
+					os.Unsetenv(key)
+				}
+			}
+			got, err := resolveWebhookUrl()
+			if len(tt.err) > 0 {
+				if err == nil || !strings.HasPrefix(err.Error(), tt.err) {
+					t.Fatalf("resolveWebhookUrl() = %q, %v, want error %q", got, err, tt.err)
+				}
+				if strings.Contains(err.Error(), "secret") {
+					t.Errorf("error %q shows the webhook url", err)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("resolveWebhookUrl() = %q, %v, want %q", got, err, tt.want)
+			}
+		})
+	}
+}